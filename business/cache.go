@@ -0,0 +1,79 @@
+package business
+
+import (
+	"calculator/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+type noCacheKey struct{}
+
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(noCacheKey{}).(bool)
+	return bypass
+}
+
+func (i *Interpreter) SetResponseCache(cache *storage.ResponseCache) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.cache = cache
+}
+
+func (i *Interpreter) responseCache(ctx context.Context, provider LLMProvider, req ChatRequest) (*storage.ResponseCache, string) {
+	i.mu.RLock()
+	cache := i.cache
+	i.mu.RUnlock()
+	if cache == nil || cacheBypassed(ctx) {
+		return nil, ""
+	}
+
+	payload, err := json.Marshal(struct {
+		Provider  string        `json:"provider"`
+		Model     string        `json:"model"`
+		Purpose   string        `json:"purpose"`
+		Messages  []ChatMessage `json:"messages"`
+		Tools     []Tool        `json:"tools,omitempty"`
+		MaxTokens int           `json:"maxTokens"`
+	}{provider.Name(), provider.ModelName(), req.Purpose, req.Messages, req.Tools, req.MaxTokens})
+	if err != nil {
+		return nil, ""
+	}
+	hash := sha256.Sum256(payload)
+	return cache, hex.EncodeToString(hash[:])
+}
+
+func (i *Interpreter) handleCacheCommand(input string) (string, error) {
+	i.mu.RLock()
+	cache := i.cache
+	i.mu.RUnlock()
+	if cache == nil {
+		return "", i.errorf("cache.disabled")
+	}
+	if i.isRemote() {
+		return "", i.errorf("cache.cli_only")
+	}
+
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) < 2 || isKeyword(fields[1], "cache_stats") {
+		stats := cache.Stats()
+		return i.sprintf("cache.stats", stats.Entries, stats.Bytes, stats.Hits, stats.Misses, stats.Evictions), nil
+	}
+	if isKeyword(fields[1], "cache_clear") {
+		if err := cache.Clear(); err != nil {
+			return "", i.errorf("cache.clear_failed", err)
+		}
+		return i.msg("cache.cleared"), nil
+	}
+	return "", i.errorf("cache.usage")
+}
+
+func (i *Interpreter) handleNoCacheCommand(ctx context.Context, input string) (*Result, error) {
+	return i.dispatch(WithoutCache(ctx), trimAlias(input, keywordsFor("nocache")))
+}
@@ -0,0 +1,165 @@
+package business
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+//go:embed classifier_rules.json
+var defaultClassifierRules []byte
+
+var classificationTypes = []string{"open_website", "calculation", "information"}
+
+type ClassifierRule struct {
+	Type       string   `json:"type"`
+	Pattern    string   `json:"pattern,omitempty"`
+	Keywords   []string `json:"keywords,omitempty"`
+	Confidence float64  `json:"confidence"`
+	pattern    *regexp.Regexp
+}
+
+func (r ClassifierRule) matches(input string) bool {
+	if r.pattern != nil && !r.pattern.MatchString(input) {
+		return false
+	}
+	if len(r.Keywords) == 0 {
+		return true
+	}
+
+	lowerInput := strings.ToLower(input)
+	for _, keyword := range r.Keywords {
+		if strings.Contains(lowerInput, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+type RuleClassifier struct {
+	Threshold float64          `json:"threshold"`
+	MinMargin float64          `json:"minMargin"`
+	Rules     []ClassifierRule `json:"rules"`
+}
+
+var defaultClassifier = mustRuleClassifier(defaultClassifierRules)
+
+func NewRuleClassifier(data []byte) (*RuleClassifier, error) {
+	var classifier RuleClassifier
+	if err := json.Unmarshal(data, &classifier); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать правила классификатора: %v", err)
+	}
+	if classifier.Threshold <= 0 || classifier.Threshold > 1 {
+		return nil, fmt.Errorf("порог классификатора должен быть в диапазоне (0, 1], получено %v", classifier.Threshold)
+	}
+	if classifier.MinMargin < 0 || classifier.MinMargin > 1 {
+		return nil, fmt.Errorf("отрыв от второго типа должен быть в диапазоне [0, 1], получено %v", classifier.MinMargin)
+	}
+
+	for idx := range classifier.Rules {
+		rule := &classifier.Rules[idx]
+		if !isClassificationType(rule.Type) {
+			return nil, fmt.Errorf("правило %d: неизвестный тип '%s'", idx+1, rule.Type)
+		}
+		if rule.Pattern == "" && len(rule.Keywords) == 0 {
+			return nil, fmt.Errorf("правило %d: нужен pattern или keywords", idx+1)
+		}
+		if rule.Confidence <= 0 || rule.Confidence > 1 {
+			return nil, fmt.Errorf("правило %d: уверенность должна быть в диапазоне (0, 1], получено %v", idx+1, rule.Confidence)
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("правило %d: некорректное регулярное выражение: %v", idx+1, err)
+			}
+			rule.pattern = pattern
+		}
+	}
+
+	return &classifier, nil
+}
+
+func LoadRuleClassifier(path string) (*RuleClassifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать правила классификатора: %v", err)
+	}
+	return NewRuleClassifier(data)
+}
+
+func mustRuleClassifier(data []byte) *RuleClassifier {
+	classifier, err := NewRuleClassifier(data)
+	if err != nil {
+		panic(err)
+	}
+	return classifier
+}
+
+func (c *RuleClassifier) Classify(input string) (string, float64, float64) {
+	scores := make(map[string]float64)
+	for _, rule := range c.Rules {
+		if rule.Confidence > scores[rule.Type] && rule.matches(input) {
+			scores[rule.Type] = rule.Confidence
+		}
+	}
+
+	bestType, best, runnerUp := "", 0.0, 0.0
+	for _, classificationType := range classificationTypes {
+		score := scores[classificationType]
+		switch {
+		case score > best:
+			bestType, best, runnerUp = classificationType, score, best
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	return bestType, best, best - runnerUp
+}
+
+func (c *RuleClassifier) Confident(confidence, margin float64) bool {
+	return confidence >= c.Threshold && margin > 0 && margin >= c.MinMargin
+}
+
+func isClassificationType(classificationType string) bool {
+	for _, known := range classificationTypes {
+		if known == classificationType {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interpreter) SetClassifier(classifier *RuleClassifier) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.classifier = classifier
+}
+
+func (i *Interpreter) classifyLocally(input string) (*RequestClassification, bool) {
+	i.mu.RLock()
+	classifier := i.classifier
+	i.mu.RUnlock()
+
+	classificationType, confidence, margin := classifier.Classify(input)
+	if classificationType == "" {
+		return nil, false
+	}
+
+	url := i.extractURLFromInput(input)
+	if url == "" {
+		url = i.extractDomainFromInput(input)
+	}
+	classification := &RequestClassification{
+		Type:       classificationType,
+		URL:        url,
+		Confidence: confidence,
+		Source:     "rules",
+	}
+	if classificationType == "open_website" && url == "" {
+		return classification, false
+	}
+	return classification, classifier.Confident(confidence, margin)
+}
@@ -0,0 +1,180 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type CommandHandler func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error)
+
+type Command struct {
+	Name        string
+	Aliases     []string
+	Args        string
+	Help        string
+	Priority    int
+	Kind        ResultKind
+	Volatile    bool
+	Fallthrough bool
+	Match       func(i *Interpreter, input string) bool
+	Reason      func(i *Interpreter, input string) string
+	Handle      CommandHandler
+}
+
+func (c Command) matches(i *Interpreter, input string) bool {
+	if c.Match != nil {
+		return c.Match(i, input)
+	}
+	return hasAliasPrefix(input, c.Aliases)
+}
+
+func hasAliasPrefix(input string, aliases []string) bool {
+	lowerInput := strings.ToLower(input)
+	for _, alias := range aliases {
+		alias = strings.ToLower(alias)
+		if lowerInput == alias || strings.HasPrefix(lowerInput, alias+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands []Command
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{}
+}
+
+var defaultRegistry = NewCommandRegistry()
+
+func RegisterCommand(cmd Command) error {
+	return defaultRegistry.Register(cmd)
+}
+
+func (r *CommandRegistry) Register(cmd Command) error {
+	if cmd.Name == "" {
+		return fmt.Errorf("у команды должно быть имя")
+	}
+	if cmd.Handle == nil {
+		return fmt.Errorf("у команды '%s' нет обработчика", cmd.Name)
+	}
+	if cmd.Match == nil && len(cmd.Aliases) == 0 {
+		return fmt.Errorf("у команды '%s' нет ни условия, ни ключевых слов", cmd.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx, existing := range r.commands {
+		if existing.Name == cmd.Name {
+			r.commands[idx] = cmd
+			r.sort()
+			return nil
+		}
+	}
+	r.commands = append(r.commands, cmd)
+	r.sort()
+	return nil
+}
+
+func UnregisterCommand(name string) {
+	defaultRegistry.Unregister(name)
+}
+
+func (r *CommandRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx, existing := range r.commands {
+		if existing.Name == name {
+			r.commands = append(r.commands[:idx], r.commands[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (r *CommandRegistry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	commands := make([]Command, len(r.commands))
+	copy(commands, r.commands)
+	return commands
+}
+
+func (r *CommandRegistry) sort() {
+	sort.SliceStable(r.commands, func(a, b int) bool {
+		return r.commands[a].Priority > r.commands[b].Priority
+	})
+}
+
+func (i *Interpreter) dispatch(ctx context.Context, input string) (*Result, error) {
+	var warnings []string
+
+	for _, cmd := range i.commands.Commands() {
+		if !cmd.matches(i, input) {
+			continue
+		}
+
+		value, handled, err := cmd.Handle(ctx, i, input)
+		if !handled {
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %v", cmd.Name, err))
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result, ok := value.(*Result)
+		if !ok {
+			result = newResult(cmd.Name, cmd.Kind, value)
+		}
+		result.Warnings = append(warnings, result.Warnings...)
+		result.volatile = cmd.Volatile
+		return result, nil
+	}
+
+	return nil, i.errorf("dispatch.no_route")
+}
+
+func (i *Interpreter) CommandKeywords() []string {
+	var keywords []string
+	for _, cmd := range i.commands.Commands() {
+		for _, alias := range cmd.Aliases {
+			if cmd.Args != "" {
+				alias += " "
+			}
+			keywords = append(keywords, alias)
+		}
+	}
+	return keywords
+}
+
+func (i *Interpreter) commandHelp() string {
+	var lines []string
+	lines = append(lines, i.msg("help.title"))
+	for _, cmd := range i.commands.Commands() {
+		var usage string
+		switch {
+		case len(cmd.Aliases) > 0:
+			usage = strings.Join(cmd.Aliases, " | ")
+			if cmd.Args != "" {
+				usage += " " + i.msg(cmd.Args)
+			}
+		case cmd.Args != "":
+			usage = i.msg(cmd.Args)
+		default:
+			usage = "(" + cmd.Name + ")"
+		}
+		lines = append(lines, fmt.Sprintf("  %s — %s", usage, i.msg(cmd.Help)))
+	}
+	return strings.Join(lines, "\n")
+}
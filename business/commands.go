@@ -0,0 +1,315 @@
+package business
+
+import (
+	"context"
+	"strings"
+)
+
+func init() {
+	builtins := []Command{
+		{
+			Name:     RouteHelp,
+			Aliases:  keywordsFor("help"),
+			Help:     "help.help",
+			Priority: 1000,
+			Kind:     ResultText,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				return i.commandHelp(), true, nil
+			},
+		},
+		{
+			Name:     RouteExplain,
+			Aliases:  keywordsFor("explain"),
+			Args:     "args.input",
+			Help:     "help.explain",
+			Priority: 950,
+			Kind:     ResultText,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.Explain(ctx, trimAlias(input, keywordsFor("explain")))
+				if err != nil {
+					return nil, true, err
+				}
+				return result.Value, true, nil
+			},
+		},
+		{
+			Name:     RouteHistory,
+			Aliases:  keywordsFor("history"),
+			Help:     "help.history",
+			Priority: 900,
+			Kind:     ResultList,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				return i.GetHistory(), true, nil
+			},
+		},
+		{
+			Name:     RouteLocale,
+			Aliases:  keywordsFor("locale"),
+			Args:     "args.locale",
+			Help:     "help.locale",
+			Priority: 850,
+			Kind:     ResultAction,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleLocaleCommand(input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteConfig,
+			Aliases:  keywordsFor("config"),
+			Args:     "args.config",
+			Help:     "help.config",
+			Priority: 840,
+			Kind:     ResultText,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleConfigCommand(input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteReset,
+			Aliases:  keywordsFor("reset"),
+			Help:     "help.reset",
+			Priority: 830,
+			Kind:     ResultAction,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				return i.handleResetCommand(), true, nil
+			},
+		},
+		{
+			Name:     RouteCache,
+			Aliases:  keywordsFor("cache"),
+			Args:     "args.cache",
+			Help:     "help.cache",
+			Priority: 820,
+			Kind:     ResultText,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleCacheCommand(input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteNoCache,
+			Aliases:  keywordsFor("nocache"),
+			Args:     "args.input",
+			Help:     "help.nocache",
+			Priority: 810,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleNoCacheCommand(ctx, input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteUsage,
+			Aliases:  keywordsFor("usage"),
+			Help:     "help.usage",
+			Priority: 805,
+			Kind:     ResultText,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				return i.handleUsageCommand(), true, nil
+			},
+		},
+		{
+			Name:     RoutePrompts,
+			Aliases:  keywordsFor("prompts"),
+			Args:     "args.prompts",
+			Help:     "help.prompts",
+			Priority: 802,
+			Kind:     ResultText,
+			Volatile: true,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handlePromptsCommand(input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteCallLogin,
+			Aliases:  keywordsFor("call_login"),
+			Args:     "args.name",
+			Help:     "help.call_login",
+			Priority: 800,
+			Kind:     ResultAction,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleCallLogin(ctx, input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteCall,
+			Aliases:  keywordsFor("call"),
+			Args:     "args.name",
+			Help:     "help.call",
+			Priority: 800,
+			Kind:     ResultAction,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleCallCommand(ctx, input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteCurl,
+			Aliases:  []string{"curl"},
+			Args:     "args.url",
+			Help:     "help.curl",
+			Priority: 700,
+			Kind:     ResultText,
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleCurlCommand(ctx, input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteAssignment,
+			Args:     "args.assignment",
+			Help:     "help.assignment",
+			Priority: 600,
+			Match: func(i *Interpreter, input string) bool {
+				variable, _, ok := splitAssignment(input)
+				return ok && isValidVariableName(variable)
+			},
+			Reason: func(i *Interpreter, input string) string {
+				variable, _, _ := splitAssignment(input)
+				return i.sprintf("explain.assignment", variable)
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				variable, expression, _ := splitAssignment(input)
+				result, err := i.handleAssignment(ctx, variable, expression)
+				return result, true, err
+			},
+		},
+		{
+			Name:        RouteOpenLink,
+			Help:        "help.open_link",
+			Priority:    500,
+			Kind:        ResultAction,
+			Fallthrough: true,
+			Match: func(i *Interpreter, input string) bool {
+				return containsLink(input)
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				return i.handleApplicationOpening(ctx, input)
+			},
+		},
+		{
+			Name:        RouteWebsiteAnalysis,
+			Help:        "help.website_analysis",
+			Priority:    450,
+			Kind:        ResultLLMAnswer,
+			Fallthrough: true,
+			Match: func(i *Interpreter, input string) bool {
+				return containsLink(input)
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleComplexRequest(ctx, input)
+				return result, err == nil, err
+			},
+		},
+		{
+			Name:     RouteSummarize,
+			Aliases:  keywordsFor("summarize"),
+			Args:     "args.file",
+			Help:     "help.summarize",
+			Priority: 440,
+			Kind:     ResultLLMAnswer,
+			Match: func(i *Interpreter, input string) bool {
+				return !containsLink(input) && hasAliasPrefix(input, keywordsFor("summarize"))
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.handleSummarizeCommand(ctx, input)
+				return result, true, err
+			},
+		},
+		{
+			Name:     RouteCalculation,
+			Help:     "help.calculation",
+			Priority: 400,
+			Match: func(i *Interpreter, input string) bool {
+				return i.isCalculableExpression(input)
+			},
+			Reason: func(i *Interpreter, input string) string {
+				_, reason := i.checkCalculable(input)
+				return i.msg(reason)
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.evaluateExpression(ctx, input)
+				return result, true, err
+			},
+		},
+		{
+			Name:        RouteNLMath,
+			Help:        "help.nl_math",
+			Priority:    350,
+			Fallthrough: true,
+			Match: func(i *Interpreter, input string) bool {
+				return i.isNaturalLanguageMath(input)
+			},
+			Reason: func(i *Interpreter, input string) string {
+				classification, _ := i.classifyLocally(input)
+				return i.sprintf("explain.nl_math", classification.Confidence)
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				return i.handleNaturalLanguageMath(ctx, input)
+			},
+		},
+		{
+			Name:        RouteOpen,
+			Help:        "help.open",
+			Priority:    300,
+			Kind:        ResultAction,
+			Fallthrough: true,
+			Match: func(i *Interpreter, input string) bool {
+				return true
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				return i.handleApplicationOpening(ctx, input)
+			},
+		},
+		{
+			Name:     RouteLLM,
+			Help:     "help.llm",
+			Priority: 0,
+			Kind:     ResultLLMAnswer,
+			Match: func(i *Interpreter, input string) bool {
+				return true
+			},
+			Handle: func(ctx context.Context, i *Interpreter, input string) (interface{}, bool, error) {
+				result, err := i.SendTextToDeepSeek(ctx, input)
+				return result, true, err
+			},
+		},
+	}
+
+	for _, cmd := range builtins {
+		RegisterCommand(cmd)
+	}
+}
+
+func splitAssignment(input string) (string, string, bool) {
+	if !strings.Contains(input, "=") || strings.Contains(input, "==") {
+		return "", "", false
+	}
+	parts := strings.SplitN(input, "=", 2)
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+func containsLink(input string) bool {
+	return strings.Contains(input, "http://") || strings.Contains(input, "https://") || strings.Contains(input, "www.")
+}
+
+func trimAlias(input string, aliases []string) string {
+	lowerInput := strings.ToLower(input)
+	for _, alias := range aliases {
+		if strings.HasPrefix(lowerInput, strings.ToLower(alias)) {
+			return strings.TrimSpace(input[len(alias):])
+		}
+	}
+	return input
+}
@@ -0,0 +1,60 @@
+package business
+
+import (
+	"calculator/config"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+func (i *Interpreter) ApplyConfig(cfg *config.Config) {
+	safeDirs := make([]string, 0, len(cfg.SafeDirs))
+	for _, dir := range cfg.SafeDirs {
+		if absPath, err := filepath.Abs(dir); err == nil {
+			safeDirs = append(safeDirs, absPath)
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.config = cfg
+	i.httpClient = &http.Client{Timeout: cfg.HTTPTimeout.Duration}
+	i.customSafeDirs = append(safeDirs, i.customSafeDirs...)
+	i.conversation.SetBudget(cfg.LLM.ConversationTokens)
+}
+
+func (i *Interpreter) Config() *config.Config {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.config
+}
+
+func (i *Interpreter) callServerURL() string {
+	return strings.TrimRight(i.Config().CallServerURL, "/")
+}
+
+func (i *Interpreter) handleConfigCommand(input string) (interface{}, error) {
+	if i.isRemote() {
+		return nil, i.errorf("config.cli_only")
+	}
+	fields := strings.Fields(input)
+	if len(fields) > 2 || len(fields) == 2 && !isKeyword(strings.ToLower(fields[1]), "config_show") {
+		return nil, i.errorf("config.usage")
+	}
+
+	cfg := i.Config()
+	source := cfg.Source
+	if source == "" {
+		source = i.msg("config.defaults")
+	}
+	return i.sprintf("config.source", source) + "\n" + cfg.String(), nil
+}
+
+func isKeyword(word string, group string) bool {
+	for _, keyword := range keywordsFor(group) {
+		if word == keyword {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package business
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	summaryTokenShare  = 4
+	stateRecentResults = 5
+	stateValueLimit    = 80
+)
+
+type Conversation struct {
+	mu      sync.Mutex
+	budget  int
+	turns   []ChatMessage
+	summary []string
+}
+
+func NewConversation(budget int) *Conversation {
+	return &Conversation{budget: budget}
+}
+
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+func (c *Conversation) SetBudget(budget int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+	c.trim()
+}
+
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turns = nil
+	c.summary = nil
+}
+
+func (c *Conversation) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.turns) / 2
+}
+
+func (c *Conversation) Add(question, answer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget <= 0 {
+		return
+	}
+
+	c.turns = append(c.turns,
+		ChatMessage{Role: "user", Content: question},
+		ChatMessage{Role: "assistant", Content: answer},
+	)
+	c.trim()
+}
+
+func (c *Conversation) snapshot() ([]ChatMessage, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ChatMessage(nil), c.turns...), append([]string(nil), c.summary...)
+}
+
+func (c *Conversation) trim() {
+	summaryBudget := c.budget / summaryTokenShare
+	turnsBudget := c.budget - summaryBudget
+
+	for len(c.turns) >= 2 && messagesTokens(c.turns) > turnsBudget {
+		question, answer := c.turns[0].Content, c.turns[1].Content
+		c.turns = c.turns[2:]
+		c.summary = append(c.summary, summarizeTurn(question, answer))
+	}
+
+	for len(c.summary) > 0 && linesTokens(c.summary) > summaryBudget {
+		c.summary = c.summary[1:]
+	}
+}
+
+func summarizeTurn(question, answer string) string {
+	return fmt.Sprintf("%s → %s", truncateText(firstLine(question), 120), truncateText(firstLine(answer), 160))
+}
+
+func messagesTokens(messages []ChatMessage) int {
+	total := 0
+	for _, message := range messages {
+		total += estimateTokens(message.Content)
+	}
+	return total
+}
+
+func linesTokens(lines []string) int {
+	total := 0
+	for _, line := range lines {
+		total += estimateTokens(line)
+	}
+	return total
+}
+
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+		return text[:idx]
+	}
+	return text
+}
+
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
+
+func (i *Interpreter) Conversation() *Conversation {
+	return i.conversation
+}
+
+func (i *Interpreter) conversationMessages(systemPrompt, question string) []ChatMessage {
+	messages := []ChatMessage{{Role: "system", Content: systemPrompt}}
+	if state := i.calculatorState(); state != "" {
+		messages = append(messages, ChatMessage{Role: "system", Content: state})
+	}
+
+	turns, summary := i.conversation.snapshot()
+	if len(summary) > 0 {
+		messages = append(messages, ChatMessage{
+			Role:    "system",
+			Content: i.msg("conversation.summary") + "\n" + strings.Join(summary, "\n"),
+		})
+	}
+	messages = append(messages, turns...)
+	return append(messages, ChatMessage{Role: "user", Content: question})
+}
+
+func (i *Interpreter) calculatorState() string {
+	lines := i.stateLines()
+	if len(lines) == 0 {
+		return ""
+	}
+	return i.msg("conversation.state") + "\n" + strings.Join(lines, "\n")
+}
+
+func (i *Interpreter) stateLines() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var lines []string
+	names := make([]string, 0, len(i.variables))
+	for name := range i.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := truncateText(firstLine(i.formatValue(i.variables[name])), stateValueLimit)
+		lines = append(lines, fmt.Sprintf("%s = %s", name, value))
+	}
+
+	start := len(i.results) - stateRecentResults
+	if start < 0 {
+		start = 0
+	}
+	for idx := start; idx < len(i.results); idx++ {
+		value := truncateText(firstLine(i.formatValue(i.results[idx])), stateValueLimit)
+		lines = append(lines, fmt.Sprintf("_%d = %s", idx+1, value))
+	}
+	if len(i.results) > 0 {
+		lines = append(lines, fmt.Sprintf("ans = _%d", len(i.results)))
+	}
+	return lines
+}
+
+func (i *Interpreter) handleResetCommand() string {
+	i.conversation.Reset()
+	return i.msg("conversation.reset")
+}
@@ -0,0 +1,447 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const curlMaxRedirects = 10
+
+type curlOptions struct {
+	method   string
+	url      string
+	headers  http.Header
+	data     []string
+	json     bool
+	user     string
+	location bool
+	head     bool
+	include  bool
+	silent   bool
+	output   string
+	maxTime  time.Duration
+}
+
+type CurlResponse struct {
+	URL        string
+	Proto      string
+	Status     string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+var curlBoolFlags = map[string]string{
+	"-L": "location", "--location": "location",
+	"-I": "head", "--head": "head",
+	"-i": "include", "--include": "include",
+	"-s": "silent", "--silent": "silent",
+	"-S": "", "--show-error": "", "--compressed": "", "-f": "", "--fail": "",
+}
+
+var curlValueFlags = map[string]string{
+	"-X": "request", "--request": "request",
+	"-H": "header", "--header": "header",
+	"-d": "data", "--data": "data", "--data-binary": "data", "--data-ascii": "data",
+	"--data-raw": "data-raw",
+	"--json":     "json",
+	"-u":         "user", "--user": "user",
+	"-o": "output", "--output": "output",
+	"-m": "max-time", "--max-time": "max-time",
+	"-A": "user-agent", "--user-agent": "user-agent",
+	"-e": "referer", "--referer": "referer",
+}
+
+func (i *Interpreter) handleCurlCommand(ctx context.Context, input string) (string, error) {
+	opts, err := i.parseCurl(input)
+	if err != nil {
+		return "", err
+	}
+	resp, err := i.executeCurl(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	if !opts.silent || opts.head || opts.include {
+		parts = append(parts, formatCurlHead(resp))
+	}
+	switch {
+	case opts.head:
+	case opts.output != "":
+		path, err := i.writeCurlOutput(opts.output, resp.Body)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, i.sprintf("curl.output_saved", len(resp.Body), path))
+	default:
+		parts = append(parts, string(resp.Body))
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+func (i *Interpreter) fetchCurlResponse(ctx context.Context, input string) (*HTTPResponse, error) {
+	opts, err := i.parseCurl(input)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := i.executeCurl(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.output != "" && !opts.head {
+		if _, err := i.writeCurlOutput(opts.output, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	return newHTTPResponse(resp), nil
+}
+
+func (i *Interpreter) fetchURL(ctx context.Context, url string) (*CurlResponse, error) {
+	return i.executeCurl(ctx, &curlOptions{method: http.MethodGet, url: url, headers: http.Header{}, location: true})
+}
+
+func (i *Interpreter) parseCurl(input string) (*curlOptions, error) {
+	args, err := splitShellWords(input)
+	if err != nil {
+		return nil, i.errorf("curl.bad_syntax", err)
+	}
+	if len(args) > 0 && strings.EqualFold(args[0], "curl") {
+		args = args[1:]
+	}
+
+	opts := &curlOptions{headers: http.Header{}}
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if opts.url != "" {
+				return nil, i.errorf("curl.extra_argument", arg)
+			}
+			opts.url = arg
+			continue
+		}
+
+		var flags []string
+		value, hasValue := "", false
+		if strings.HasPrefix(arg, "--") {
+			flags = []string{arg}
+		} else {
+			for pos := 1; pos < len(arg); pos++ {
+				flag := "-" + string(arg[pos])
+				flags = append(flags, flag)
+				if _, ok := curlValueFlags[flag]; ok && pos+1 < len(arg) {
+					value, hasValue = arg[pos+1:], true
+					break
+				}
+			}
+		}
+
+		for _, flag := range flags {
+			if name, ok := curlBoolFlags[flag]; ok {
+				opts.setFlag(name)
+				continue
+			}
+			name, ok := curlValueFlags[flag]
+			if !ok {
+				return nil, i.errorf("curl.unknown_option", flag)
+			}
+			if !hasValue {
+				if idx+1 >= len(args) {
+					return nil, i.errorf("curl.missing_value", flag)
+				}
+				idx++
+				value = args[idx]
+			}
+			if err := i.setCurlOption(opts, flag, name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.url == "" {
+		return nil, i.errorf("curl.url_missing")
+	}
+	if !strings.Contains(opts.url, "://") {
+		opts.url = "http://" + opts.url
+	}
+	if opts.method == "" {
+		switch {
+		case opts.head:
+			opts.method = http.MethodHead
+		case len(opts.data) > 0:
+			opts.method = http.MethodPost
+		default:
+			opts.method = http.MethodGet
+		}
+	}
+	return opts, nil
+}
+
+func (o *curlOptions) setFlag(name string) {
+	switch name {
+	case "location":
+		o.location = true
+	case "head":
+		o.head = true
+	case "include":
+		o.include = true
+	case "silent":
+		o.silent = true
+	}
+}
+
+func (i *Interpreter) setCurlOption(opts *curlOptions, flag, name, value string) error {
+	switch name {
+	case "request":
+		opts.method = strings.ToUpper(value)
+	case "header":
+		key, headerValue, ok := strings.Cut(value, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			return i.errorf("curl.bad_header", value)
+		}
+		opts.headers.Add(strings.TrimSpace(key), strings.TrimSpace(headerValue))
+	case "data":
+		if strings.HasPrefix(value, "@") {
+			return i.errorf("curl.data_file_unsupported", flag)
+		}
+		opts.data = append(opts.data, value)
+	case "data-raw":
+		opts.data = append(opts.data, value)
+	case "json":
+		if strings.HasPrefix(value, "@") {
+			return i.errorf("curl.data_file_unsupported", flag)
+		}
+		opts.data = append(opts.data, value)
+		opts.json = true
+	case "user":
+		opts.user = value
+	case "output":
+		path, err := i.curlOutputPath(value)
+		if err != nil {
+			return err
+		}
+		opts.output = path
+	case "max-time":
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			return i.errorf("curl.bad_max_time", value)
+		}
+		opts.maxTime = time.Duration(seconds * float64(time.Second))
+	case "user-agent":
+		opts.headers.Set("User-Agent", value)
+	case "referer":
+		opts.headers.Set("Referer", value)
+	}
+	return nil
+}
+
+func (i *Interpreter) executeCurl(ctx context.Context, opts *curlOptions) (*CurlResponse, error) {
+	if opts.maxTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.maxTime)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if len(opts.data) > 0 {
+		separator := "&"
+		if opts.json {
+			separator = ""
+		}
+		body = strings.NewReader(strings.Join(opts.data, separator))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, opts.method, opts.url, body)
+	if err != nil {
+		return nil, i.errorf("curl.request_failed", err)
+	}
+	policy := i.outboundPolicy()
+	if err := i.checkOutboundURL(policy, req.URL); err != nil {
+		return nil, policyViolation(err)
+	}
+	for key, values := range opts.headers {
+		req.Header[key] = values
+	}
+	if opts.json {
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if req.Header.Get("Accept") == "" {
+			req.Header.Set("Accept", "application/json")
+		}
+	} else if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if opts.user != "" {
+		username, password, _ := strings.Cut(opts.user, ":")
+		req.SetBasicAuth(username, password)
+	}
+
+	client, transport := i.outboundClient(policy, opts.location)
+	defer transport.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if blocked := policyViolation(err); blocked != nil {
+			return nil, blocked
+		}
+		return nil, i.errorf("curl.do_failed", err)
+	}
+	defer resp.Body.Close()
+
+	if err := i.checkContentType(policy, resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+	limit := policy.cfg.MaxResponseBytes
+	if resp.ContentLength > limit {
+		return nil, i.errorf("curl.too_large", limit)
+	}
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, i.errorf("curl.read_failed", err)
+	}
+	if int64(len(responseBody)) > limit {
+		return nil, i.errorf("curl.too_large", limit)
+	}
+
+	return &CurlResponse{
+		URL:        resp.Request.URL.String(),
+		Proto:      resp.Proto,
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       responseBody,
+	}, nil
+}
+
+func formatCurlHead(resp *CurlResponse) string {
+	lines := []string{resp.Proto + " " + resp.Status}
+	keys := make([]string, 0, len(resp.Header))
+	for key := range resp.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range resp.Header[key] {
+			lines = append(lines, fmt.Sprintf("%s: %s", key, value))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (i *Interpreter) curlOutputPath(output string) (string, error) {
+	if i.isRemote() {
+		return "", i.errorf("curl.output_remote")
+	}
+	path, err := filepath.Abs(output)
+	if err != nil {
+		return "", i.errorf("curl.write_failed", err)
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return "", i.errorf("curl.write_failed", err)
+	}
+	path = filepath.Join(dir, filepath.Base(path))
+	if !i.isInSafeDirectory(path) {
+		return "", i.errorf("curl.output_not_allowed", path)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return "", i.errorf("curl.output_exists", path)
+	}
+	return path, nil
+}
+
+func (i *Interpreter) writeCurlOutput(path string, body []byte) (string, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return "", i.errorf("curl.output_exists", path)
+	}
+	if err != nil {
+		return "", i.errorf("curl.write_failed", err)
+	}
+	if _, err := file.Write(body); err != nil {
+		file.Close()
+		return "", i.errorf("curl.write_failed", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", i.errorf("curl.write_failed", err)
+	}
+	return path, nil
+}
+
+func (i *Interpreter) isInSafeDirectory(path string) bool {
+	dir := filepath.Dir(path)
+	for _, safeDir := range i.getSafeDirectories() {
+		if resolved, err := filepath.EvalSymlinks(safeDir); err == nil {
+			safeDir = resolved
+		}
+		if rel, err := filepath.Rel(safeDir, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitShellWords(input string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(input)
+	for pos := 0; pos < len(runes); pos++ {
+		r := runes[pos]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && pos+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[pos+1]):
+				pos++
+				current.WriteRune(runes[pos])
+			default:
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\' && pos+1 < len(runes):
+			pos++
+			if runes[pos] != '\n' {
+				current.WriteRune(runes[pos])
+				inWord = true
+			}
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("незакрытая кавычка %c", quote)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,177 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type EvalStep struct {
+	Expr     string      `json:"expr"`
+	Op       string      `json:"op,omitempty"`
+	Rewrites []string    `json:"rewrites,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Children []*EvalStep `json:"children,omitempty"`
+}
+
+type traceKey struct{}
+
+func traceStep(ctx context.Context, expr string, op string) (context.Context, *EvalStep) {
+	parent, ok := ctx.Value(traceKey{}).(*EvalStep)
+	if !ok {
+		return ctx, nil
+	}
+	step := &EvalStep{Expr: expr, Op: op}
+	parent.Children = append(parent.Children, step)
+	return context.WithValue(ctx, traceKey{}, step), step
+}
+
+func (s *EvalStep) setOp(op string) {
+	if s != nil {
+		s.Op = op
+	}
+}
+
+func (s *EvalStep) rewrite(expr string) {
+	if s != nil {
+		s.Rewrites = append(s.Rewrites, expr)
+	}
+}
+
+func (s *EvalStep) finish(value string, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.Error = err.Error()
+		return
+	}
+	s.Value = value
+}
+
+type RouteCheck struct {
+	Name    string `json:"name"`
+	Matched bool   `json:"matched"`
+}
+
+type Explanation struct {
+	Input       string       `json:"input"`
+	Route       string       `json:"route"`
+	Reason      string       `json:"reason"`
+	Fallthrough bool         `json:"fallthrough,omitempty"`
+	Checked     []RouteCheck `json:"checked"`
+	Steps       []*EvalStep  `json:"steps,omitempty"`
+	locale      Locale
+}
+
+func (i *Interpreter) Explain(ctx context.Context, input string) (*Result, error) {
+	input = strings.TrimSpace(input)
+	explanation := &Explanation{Input: input, locale: i.Locale()}
+
+	for _, cmd := range i.commands.Commands() {
+		matched := cmd.matches(i, input)
+		explanation.Checked = append(explanation.Checked, RouteCheck{Name: cmd.Name, Matched: matched})
+		if matched && explanation.Route == "" {
+			explanation.Route = cmd.Name
+			explanation.Reason = i.routeReason(cmd, input)
+			explanation.Fallthrough = cmd.Fallthrough
+		}
+	}
+
+	if expression, ok := i.explainedExpression(explanation.Route, input); ok {
+		root := &EvalStep{Expr: expression}
+		traceCtx := context.WithValue(ctx, traceKey{}, root)
+		value, err := i.evaluateExpression(traceCtx, expression)
+		root.finish(i.valueToString(value), err)
+		explanation.Steps = root.Children
+	}
+
+	result := newResult(RouteExplain, ResultText, explanation)
+	result.Text = explanation.String()
+	return result, nil
+}
+
+func (i *Interpreter) routeReason(cmd Command, input string) string {
+	if cmd.Reason != nil {
+		return cmd.Reason(i, input)
+	}
+
+	lowerInput := strings.ToLower(input)
+	for _, alias := range cmd.Aliases {
+		if lowerInput == strings.ToLower(alias) || strings.HasPrefix(lowerInput, strings.ToLower(alias)+" ") {
+			return i.sprintf("explain.alias", alias)
+		}
+	}
+	return i.msg(cmd.Help)
+}
+
+func (i *Interpreter) explainedExpression(route string, input string) (string, bool) {
+	switch route {
+	case RouteCalculation:
+		return input, true
+	case RouteAssignment:
+		_, expression, ok := splitAssignment(input)
+		if ok && !strings.HasPrefix(strings.ToLower(expression), "curl ") {
+			return expression, true
+		}
+	}
+	return "", false
+}
+
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", Message(e.locale, "explain.input"), e.Input)
+	fmt.Fprintf(&b, "%s: %s\n", Message(e.locale, "explain.route"), e.Route)
+	fmt.Fprintf(&b, "%s: %s\n", Message(e.locale, "explain.reason"), e.Reason)
+
+	fmt.Fprintf(&b, "%s:\n", Message(e.locale, "explain.checked"))
+	var fallbacks []string
+	routeFound := false
+	for _, check := range e.Checked {
+		if routeFound {
+			if check.Matched {
+				fallbacks = append(fallbacks, check.Name)
+			}
+			continue
+		}
+		mark := "✗"
+		if check.Matched {
+			mark = "✓"
+		}
+		fmt.Fprintf(&b, "  %s %s\n", mark, check.Name)
+		routeFound = check.Name == e.Route
+	}
+	if e.Fallthrough && len(fallbacks) > 0 {
+		fmt.Fprintf(&b, "%s: %s\n", Message(e.locale, "explain.fallbacks"), strings.Join(fallbacks, " → "))
+	}
+
+	if len(e.Steps) > 0 {
+		fmt.Fprintf(&b, "%s:\n", Message(e.locale, "explain.steps"))
+		for _, step := range e.Steps {
+			writeStep(&b, step, 1)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func writeStep(b *strings.Builder, step *EvalStep, depth int) {
+	indent := strings.Repeat("  ", depth)
+	line := step.Expr
+	for _, rewrite := range step.Rewrites {
+		line += " → " + rewrite
+	}
+	if step.Op != "" && step.Op != "expression" {
+		line = fmt.Sprintf("[%s] %s", step.Op, line)
+	}
+	if step.Error != "" {
+		line += " ⇒ ✗ " + step.Error
+	} else {
+		line += " ⇒ " + step.Value
+	}
+	fmt.Fprintf(b, "%s%s\n", indent, line)
+
+	for _, child := range step.Children {
+		writeStep(b, child, depth+1)
+	}
+}
@@ -0,0 +1,598 @@
+package business
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type PageLink struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+type PageContent struct {
+	Title    string       `json:"title"`
+	Headings []string     `json:"headings,omitempty"`
+	Text     string       `json:"text"`
+	Links    []PageLink   `json:"links,omitempty"`
+	Tables   [][][]string `json:"tables,omitempty"`
+}
+
+type htmlTokenKind int
+
+const (
+	htmlText htmlTokenKind = iota
+	htmlStartTag
+	htmlEndTag
+)
+
+type htmlToken struct {
+	kind        htmlTokenKind
+	name        string
+	attrs       map[string]string
+	text        string
+	selfClosing bool
+	wrapsMain   bool
+}
+
+var (
+	htmlRawTextTags = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+	htmlVoidTags = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+		"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	}
+
+	htmlSkipTags = map[string]bool{
+		"script": true, "style": true, "noscript": true, "template": true, "svg": true, "canvas": true,
+		"iframe": true, "object": true, "nav": true, "header": true, "footer": true, "aside": true,
+		"form": true, "button": true, "select": true, "textarea": true, "dialog": true, "head": true,
+	}
+
+	htmlBlockTags = map[string]bool{
+		"p": true, "div": true, "section": true, "article": true, "main": true, "ul": true, "ol": true,
+		"li": true, "dl": true, "dt": true, "dd": true, "blockquote": true, "pre": true, "br": true,
+		"hr": true, "figure": true, "figcaption": true, "address": true, "details": true, "summary": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "table": true, "caption": true,
+	}
+
+	htmlBoilerplateTags = map[string]bool{
+		"div": true, "section": true, "ul": true, "ol": true, "li": true, "p": true, "span": true,
+	}
+
+	htmlBoilerplateWords = map[string]bool{
+		"cookie": true, "cookies": true, "banner": true, "menu": true, "navbar": true, "sidebar": true,
+		"breadcrumb": true, "breadcrumbs": true, "advert": true, "ads": true, "promo": true, "share": true,
+		"social": true, "popup": true, "modal": true, "subscribe": true, "related": true,
+	}
+
+	htmlBoilerplateModifiers = map[string]bool{"has": true, "with": true, "no": true, "without": true}
+)
+
+func ExtractPage(body, pageURL string) *PageContent {
+	base, _ := url.Parse(pageURL)
+	extractor := &pageExtractor{page: &PageContent{}, base: base, seenLinks: make(map[string]bool)}
+	tokens := tokenizeHTML(body)
+	extractor.scoped = markMainWrappers(tokens)
+	for _, token := range tokens {
+		extractor.handle(token)
+	}
+	extractor.flush()
+	extractor.page.Text = strings.Join(extractor.lines, "\n")
+	return extractor.page
+}
+
+func (i *Interpreter) extractPage(resp *CurlResponse) *PageContent {
+	body := string(resp.Body)
+	if !isHTMLResponse(resp) {
+		return &PageContent{Text: strings.TrimSpace(body)}
+	}
+
+	page := ExtractPage(body, resp.URL)
+	if limit := i.Config().Website.MaxLinks; len(page.Links) > limit {
+		page.Links = page.Links[:limit]
+	}
+	return page
+}
+
+func isHTMLResponse(resp *CurlResponse) bool {
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if contentType != "" {
+		return strings.Contains(contentType, "html")
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(resp.Body)), "<")
+}
+
+func tokenizeHTML(src string) []htmlToken {
+	var tokens []htmlToken
+	for pos := 0; pos < len(src); {
+		if src[pos] != '<' {
+			end := strings.IndexByte(src[pos:], '<')
+			if end < 0 {
+				end = len(src) - pos
+			}
+			tokens = append(tokens, htmlToken{kind: htmlText, text: src[pos : pos+end]})
+			pos += end
+			continue
+		}
+
+		rest := src[pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return tokens
+			}
+			pos += 4 + end + 3
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return tokens
+			}
+			pos += end + 1
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return tokens
+			}
+			if fields := strings.Fields(rest[2:end]); len(fields) > 0 {
+				tokens = append(tokens, htmlToken{kind: htmlEndTag, name: strings.ToLower(fields[0])})
+			}
+			pos += end + 1
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			token, next := parseHTMLTag(src, pos)
+			tokens = append(tokens, token)
+			pos = next
+			if !htmlRawTextTags[token.name] || token.selfClosing {
+				continue
+			}
+			closing := indexASCIIFold(src[pos:], "</"+token.name)
+			if closing < 0 {
+				closing = len(src) - pos
+			}
+			tokens = append(tokens, htmlToken{kind: htmlText, text: src[pos : pos+closing]}, htmlToken{kind: htmlEndTag, name: token.name})
+			pos += closing
+			if end := strings.IndexByte(src[pos:], '>'); end >= 0 {
+				pos += end + 1
+			} else {
+				pos = len(src)
+			}
+		default:
+			tokens = append(tokens, htmlToken{kind: htmlText, text: "<"})
+			pos++
+		}
+	}
+	return tokens
+}
+
+func parseHTMLTag(src string, start int) (htmlToken, int) {
+	pos := start + 1
+	for pos < len(src) && !isHTMLSpace(src[pos]) && src[pos] != '>' && src[pos] != '/' {
+		pos++
+	}
+	token := htmlToken{kind: htmlStartTag, name: strings.ToLower(src[start+1 : pos]), attrs: make(map[string]string)}
+
+	for pos < len(src) {
+		for pos < len(src) && isHTMLSpace(src[pos]) {
+			pos++
+		}
+		if pos >= len(src) {
+			break
+		}
+		if src[pos] == '>' {
+			return token, pos + 1
+		}
+		if src[pos] == '/' {
+			token.selfClosing = true
+			pos++
+			continue
+		}
+
+		nameStart := pos
+		for pos < len(src) && !isHTMLSpace(src[pos]) && src[pos] != '=' && src[pos] != '>' && src[pos] != '/' {
+			pos++
+		}
+		name := strings.ToLower(src[nameStart:pos])
+		for pos < len(src) && isHTMLSpace(src[pos]) {
+			pos++
+		}
+		if pos >= len(src) || src[pos] != '=' {
+			token.attrs[name] = ""
+			continue
+		}
+		pos++
+		for pos < len(src) && isHTMLSpace(src[pos]) {
+			pos++
+		}
+
+		var value string
+		if pos < len(src) && (src[pos] == '"' || src[pos] == '\'') {
+			quote := src[pos]
+			end := strings.IndexByte(src[pos+1:], quote)
+			if end < 0 {
+				value, pos = src[pos+1:], len(src)
+			} else {
+				value, pos = src[pos+1:pos+1+end], pos+end+2
+			}
+		} else {
+			valueStart := pos
+			for pos < len(src) && !isHTMLSpace(src[pos]) && src[pos] != '>' {
+				pos++
+			}
+			value = src[valueStart:pos]
+		}
+		token.attrs[name] = html.UnescapeString(value)
+	}
+	return token, len(src)
+}
+
+func isHTMLSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\r' || char == '\f'
+}
+
+func isASCIILetter(char byte) bool {
+	return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z'
+}
+
+func indexASCIIFold(s, substr string) int {
+	for start := 0; start+len(substr) <= len(s); start++ {
+		matched := true
+		for offset := 0; offset < len(substr); offset++ {
+			if lowerASCII(s[start+offset]) != lowerASCII(substr[offset]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return start
+		}
+	}
+	return -1
+}
+
+func lowerASCII(char byte) byte {
+	if char >= 'A' && char <= 'Z' {
+		return char + 'a' - 'A'
+	}
+	return char
+}
+
+func markMainWrappers(tokens []htmlToken) bool {
+	found := false
+	var open []int
+	for idx, token := range tokens {
+		switch token.kind {
+		case htmlStartTag:
+			if isMainContentTag(token) {
+				found = true
+				for _, parent := range open {
+					tokens[parent].wrapsMain = true
+				}
+			}
+			if !htmlVoidTags[token.name] && !token.selfClosing {
+				open = append(open, idx)
+			}
+		case htmlEndTag:
+			for depth := len(open) - 1; depth >= 0; depth-- {
+				if tokens[open[depth]].name == token.name {
+					open = open[:depth]
+					break
+				}
+			}
+		}
+	}
+	return found
+}
+
+func isBoilerplateElement(token htmlToken) bool {
+	if !htmlBoilerplateTags[token.name] {
+		return false
+	}
+	for _, class := range strings.Fields(strings.ToLower(token.attrs["class"] + " " + token.attrs["id"])) {
+		parts := strings.FieldsFunc(class, func(r rune) bool { return r == '-' || r == '_' })
+		for idx, part := range parts {
+			if htmlBoilerplateWords[part] && (idx == 0 || !htmlBoilerplateModifiers[parts[idx-1]]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isMainContentTag(token htmlToken) bool {
+	return token.name == "main" || token.name == "article" || token.attrs["role"] == "main"
+}
+
+type htmlElement struct {
+	name string
+	skip bool
+	main bool
+}
+
+type pageExtractor struct {
+	page      *PageContent
+	base      *url.URL
+	scoped    bool
+	stack     []htmlElement
+	skipDepth int
+	mainDepth int
+
+	lines   []string
+	line    strings.Builder
+	prefix  string
+	inTitle bool
+
+	inLink bool
+	href   string
+	anchor strings.Builder
+
+	seenLinks map[string]bool
+
+	table      [][]string
+	row        []string
+	cell       strings.Builder
+	inCell     bool
+	tableDepth int
+}
+
+func (e *pageExtractor) handle(token htmlToken) {
+	switch token.kind {
+	case htmlText:
+		e.text(html.UnescapeString(token.text))
+	case htmlStartTag:
+		e.start(token)
+	case htmlEndTag:
+		e.end(token.name)
+	}
+}
+
+func (e *pageExtractor) collecting() bool {
+	return e.skipDepth == 0 && (!e.scoped || e.mainDepth > 0)
+}
+
+func (e *pageExtractor) start(token htmlToken) {
+	if token.name == "title" {
+		e.inTitle = true
+		return
+	}
+	if htmlVoidTags[token.name] || token.selfClosing {
+		if token.name == "br" || token.name == "hr" {
+			e.flush()
+		}
+		return
+	}
+
+	element := htmlElement{name: token.name, main: isMainContentTag(token)}
+	element.skip = !element.main && !token.wrapsMain && (htmlSkipTags[token.name] || isBoilerplateElement(token))
+	e.stack = append(e.stack, element)
+	if element.skip {
+		e.skipDepth++
+	}
+	if element.main {
+		e.mainDepth++
+	}
+	if !e.collecting() {
+		return
+	}
+
+	switch token.name {
+	case "table":
+		e.tableDepth++
+		if e.tableDepth == 1 {
+			e.flush()
+			e.table = nil
+		}
+	case "tr":
+		if e.tableDepth == 1 {
+			e.row = nil
+		}
+	case "td", "th":
+		if e.tableDepth == 1 {
+			e.cell.Reset()
+			e.inCell = true
+		} else {
+			e.flush()
+		}
+	case "a":
+		e.inLink = true
+		e.href = token.attrs["href"]
+		e.anchor.Reset()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		e.flush()
+		e.prefix = strings.Repeat("#", int(token.name[1]-'0')) + " "
+	case "li":
+		e.flush()
+		e.prefix = "- "
+	default:
+		if htmlBlockTags[token.name] {
+			e.flush()
+		}
+	}
+}
+
+func (e *pageExtractor) end(name string) {
+	if name == "title" {
+		e.inTitle = false
+		return
+	}
+
+	open := -1
+	for idx := len(e.stack) - 1; idx >= 0; idx-- {
+		if e.stack[idx].name == name {
+			open = idx
+			break
+		}
+	}
+	if open < 0 {
+		return
+	}
+
+	collecting := e.collecting()
+	for idx := len(e.stack) - 1; idx >= open; idx-- {
+		if e.stack[idx].skip {
+			e.skipDepth--
+		}
+		if e.stack[idx].main {
+			e.mainDepth--
+		}
+	}
+	e.stack = e.stack[:open]
+	if !collecting {
+		return
+	}
+
+	switch name {
+	case "td", "th":
+		if e.tableDepth == 1 && e.inCell {
+			e.row = append(e.row, collapseSpaces(e.cell.String()))
+			e.inCell = false
+		}
+	case "tr":
+		if e.tableDepth == 1 && len(e.row) > 0 {
+			e.table = append(e.table, e.row)
+			e.row = nil
+		}
+	case "table":
+		e.tableDepth--
+		if e.tableDepth == 0 {
+			e.finishTable()
+		}
+	case "a":
+		e.finishLink()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if heading := collapseSpaces(e.line.String()); heading != "" {
+			e.page.Headings = append(e.page.Headings, heading)
+		}
+		e.flush()
+	default:
+		if htmlBlockTags[name] {
+			e.flush()
+		}
+	}
+}
+
+func (e *pageExtractor) text(text string) {
+	if e.inTitle {
+		if e.page.Title == "" {
+			e.page.Title = collapseSpaces(text)
+		}
+		return
+	}
+	if !e.collecting() {
+		return
+	}
+
+	if e.inLink {
+		e.anchor.WriteString(text)
+	}
+	if e.inCell {
+		e.cell.WriteString(text)
+		return
+	}
+	e.line.WriteString(text)
+}
+
+func (e *pageExtractor) flush() {
+	if e.inCell {
+		e.cell.WriteByte(' ')
+		return
+	}
+	text := collapseSpaces(e.line.String())
+	e.line.Reset()
+	if text != "" {
+		e.lines = append(e.lines, e.prefix+text)
+	}
+	e.prefix = ""
+}
+
+func (e *pageExtractor) finishTable() {
+	if len(e.table) == 0 {
+		return
+	}
+	e.page.Tables = append(e.page.Tables, e.table)
+	for _, row := range e.table {
+		e.lines = append(e.lines, "| "+strings.Join(row, " | ")+" |")
+	}
+	e.table = nil
+}
+
+func (e *pageExtractor) finishLink() {
+	e.inLink = false
+	text := collapseSpaces(e.anchor.String())
+	href := strings.TrimSpace(e.href)
+	if text == "" || href == "" || strings.HasPrefix(href, "#") {
+		return
+	}
+
+	target, err := url.Parse(href)
+	if err != nil {
+		return
+	}
+	if e.base != nil {
+		target = e.base.ResolveReference(target)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return
+	}
+	target.Fragment = ""
+	link := target.String()
+	if e.seenLinks[link] {
+		return
+	}
+	e.seenLinks[link] = true
+	e.page.Links = append(e.page.Links, PageLink{Text: text, URL: link})
+}
+
+func collapseSpaces(text string) string {
+	return strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+}
+
+func chunkText(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+
+	push := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		for utf8.RuneCountInString(line) > size {
+			push()
+			head, tail := splitAtWord(line, size)
+			chunks = append(chunks, head)
+			line = tail
+		}
+		lineLen := utf8.RuneCountInString(line)
+		if currentLen > 0 && currentLen+1+lineLen > size {
+			push()
+		}
+		if currentLen > 0 {
+			current.WriteByte('\n')
+			currentLen++
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	push()
+	return chunks
+}
+
+func splitAtWord(line string, size int) (string, string) {
+	runes := []rune(line)
+	cut := size
+	for pos := size; pos > size/2; pos-- {
+		if unicode.IsSpace(runes[pos]) {
+			cut = pos
+			break
+		}
+	}
+	return strings.TrimSpace(string(runes[:cut])), strings.TrimSpace(string(runes[cut:]))
+}
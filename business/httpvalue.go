@@ -0,0 +1,302 @@
+package business
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type HTTPResponse struct {
+	URL        string            `json:"url"`
+	Status     int               `json:"status"`
+	StatusText string            `json:"statusText"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	JSON       interface{}       `json:"json,omitempty"`
+}
+
+func newHTTPResponse(resp *CurlResponse) *HTTPResponse {
+	value := &HTTPResponse{
+		URL:        resp.URL,
+		Status:     resp.StatusCode,
+		StatusText: strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		Headers:    make(map[string]string, len(resp.Header)),
+		Body:       string(resp.Body),
+	}
+	for key, values := range resp.Header {
+		value.Headers[strings.ToLower(key)] = strings.Join(values, ", ")
+	}
+
+	trimmed := strings.TrimSpace(value.Body)
+	if strings.Contains(value.Headers["content-type"], "json") || strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(trimmed), &parsed); err == nil {
+			value.JSON = parsed
+		}
+	}
+	return value
+}
+
+func (r *HTTPResponse) String() string {
+	return r.Body
+}
+
+func (r *HTTPResponse) field(name string) (interface{}, bool) {
+	switch strings.ToLower(name) {
+	case "status":
+		return float64(r.Status), true
+	case "statustext":
+		return r.StatusText, true
+	case "ok":
+		return r.Status >= 200 && r.Status < 300, true
+	case "url":
+		return r.URL, true
+	case "headers":
+		headers := make(map[string]interface{}, len(r.Headers))
+		for key, value := range r.Headers {
+			headers[key] = value
+		}
+		return headers, true
+	case "body":
+		if r.JSON != nil {
+			return r.JSON, true
+		}
+		return r.Body, true
+	case "text":
+		return r.Body, true
+	case "json":
+		return r.JSON, r.JSON != nil
+	default:
+		return nil, false
+	}
+}
+
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s pathSegment) String() string {
+	if s.isIndex {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return "." + s.key
+}
+
+func splitPathRoot(token string) (string, string) {
+	end := strings.IndexAny(token, ".[")
+	if end < 0 {
+		return token, ""
+	}
+	return token[:end], token[end:]
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	for pos := 0; pos < len(path); {
+		switch path[pos] {
+		case '.':
+			end := pos + 1
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			if end == pos+1 {
+				return nil, fmt.Errorf("пустое имя поля в позиции %d", pos+1)
+			}
+			segments = append(segments, pathSegment{key: path[pos+1 : end]})
+			pos = end
+		case '[':
+			end := strings.IndexByte(path[pos:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("не закрыта скобка [ в позиции %d", pos+1)
+			}
+			inner := strings.TrimSpace(path[pos+1 : pos+end])
+			pos += end + 1
+			if unquoted, ok := unquotePathKey(inner); ok {
+				segments = append(segments, pathSegment{key: unquoted})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("некорректный индекс [%s]", inner)
+			}
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+		default:
+			return nil, fmt.Errorf("неожиданный символ '%c' в позиции %d", path[pos], pos+1)
+		}
+	}
+	return segments, nil
+}
+
+func unquotePathKey(text string) (string, bool) {
+	if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') && text[len(text)-1] == text[0] {
+		return text[1 : len(text)-1], true
+	}
+	return "", false
+}
+
+func walkPath(value interface{}, segments []pathSegment) (interface{}, error) {
+	walked := ""
+	for idx, segment := range segments {
+		switch current := value.(type) {
+		case *HTTPResponse:
+			field, ok := current.field(segment.key)
+			if segment.isIndex || !ok {
+				return nil, fmt.Errorf("у HTTP-ответа нет поля %s", segment)
+			}
+			value = field
+		case map[string]interface{}:
+			if segment.isIndex {
+				return nil, fmt.Errorf("%s: объект нельзя индексировать числом", walked)
+			}
+			field, ok := current[segment.key]
+			if !ok {
+				field, ok = current[strings.ToLower(segment.key)]
+			}
+			if !ok {
+				return nil, fmt.Errorf("поле %s%s не найдено", walked, segment)
+			}
+			value = field
+		case []interface{}:
+			if !segment.isIndex {
+				return nil, fmt.Errorf("%s: массив нельзя читать по имени поля", walked)
+			}
+			if segment.index >= len(current) {
+				return nil, fmt.Errorf("индекс %s%s вне массива длины %d", walked, segment, len(current))
+			}
+			value = current[segment.index]
+		case string:
+			var parsed interface{}
+			if err := json.Unmarshal([]byte(current), &parsed); err != nil {
+				return nil, fmt.Errorf("%s: строка не является JSON", walked)
+			}
+			return walkPath(parsed, segments[idx:])
+		default:
+			return nil, fmt.Errorf("%s: у значения нет поля %s", walked, segment)
+		}
+		walked += segment.String()
+	}
+	return value, nil
+}
+
+func (i *Interpreter) resolvePath(token string) (interface{}, bool, error) {
+	root, path := splitPathRoot(token)
+	if root == "" || path == "" {
+		return nil, false, nil
+	}
+	value, ok := i.lookupRoot(root)
+	if !ok {
+		return nil, false, nil
+	}
+
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, true, err
+	}
+	resolved, err := walkPath(value, segments)
+	return resolved, true, err
+}
+
+func (i *Interpreter) checkPaths(expr string) error {
+	for _, token := range i.tokenizeExpression(expr) {
+		if _, isPath, err := i.resolvePath(token); isPath && err != nil {
+			return i.errorf("calc.bad_path", token, err)
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) expandJSONPath(expr string) (string, interface{}, bool, error) {
+	for {
+		start := indexASCIIFold(expr, "jsonpath(")
+		if start < 0 || (start > 0 && isIdentifierRune(rune(expr[start-1]))) {
+			return expr, nil, false, nil
+		}
+
+		open := start + len("jsonpath")
+		end, err := matchingParen(expr, open)
+		if err != nil {
+			return "", nil, false, i.errorf("calc.jsonpath_syntax", err)
+		}
+		value, err := i.callJSONPath(expr[open+1 : end])
+		if err != nil {
+			return "", nil, false, err
+		}
+
+		if start == 0 && strings.TrimSpace(expr[end+1:]) == "" {
+			return expr, value, true, nil
+		}
+		if _, isNumber := value.(float64); !isNumber {
+			if _, isBool := value.(bool); !isBool {
+				return "", nil, false, i.errorf("calc.string_arithmetic")
+			}
+		}
+		expr = expr[:start] + i.valueToString(value) + expr[end+1:]
+	}
+}
+
+func (i *Interpreter) callJSONPath(args string) (interface{}, error) {
+	comma := strings.LastIndex(args, ",")
+	if comma < 0 {
+		return nil, i.errorf("calc.jsonpath_usage")
+	}
+	source := strings.TrimSpace(args[:comma])
+	path, quoted := unquotePathKey(strings.TrimSpace(args[comma+1:]))
+	if !quoted || !strings.HasPrefix(path, "$") {
+		return nil, i.errorf("calc.jsonpath_usage")
+	}
+
+	value, ok := i.lookupVariable(source)
+	if !ok {
+		return nil, i.errorf("calc.jsonpath_source", source)
+	}
+	if response, isResponse := value.(*HTTPResponse); isResponse {
+		if response.JSON == nil {
+			return nil, i.errorf("calc.jsonpath_not_json", source)
+		}
+		value = response.JSON
+	}
+
+	segments, err := parsePath(path[1:])
+	if err != nil {
+		return nil, i.errorf("calc.bad_path", path, err)
+	}
+	resolved, err := walkPath(value, segments)
+	if err != nil {
+		return nil, i.errorf("calc.bad_path", path, err)
+	}
+	return resolved, nil
+}
+
+func matchingParen(expr string, open int) (int, error) {
+	depth := 0
+	var quote byte
+	for pos := open; pos < len(expr); pos++ {
+		char := expr[pos]
+		switch {
+		case quote != 0:
+			if char == '\\' {
+				pos++
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+			if depth == 0 {
+				return pos, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("не закрыта скобка")
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
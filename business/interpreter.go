@@ -2,26 +2,33 @@ package business
 
 import (
 	"bytes"
+	"calculator/config"
 	"calculator/storage"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 type RequestClassification struct {
-	Type        string `json:"type"`
-	URL         string `json:"url,omitempty"`
-	Action      string `json:"action,omitempty"`
-	Description string `json:"description,omitempty"`
+	Type        string  `json:"type"`
+	URL         string  `json:"url,omitempty"`
+	Action      string  `json:"action,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Confidence  float64 `json:"confidence,omitempty"`
+	Source      string  `json:"source,omitempty"`
 }
 
 type ContentSummaryRequest struct {
@@ -29,65 +36,103 @@ type ContentSummaryRequest struct {
 	Task    string `json:"task"`
 }
 
+const historySize = 10
+
 type Interpreter struct {
+	mu             sync.RWMutex
 	variables      map[string]interface{}
 	historyRepo    *storage.HistoryRepository
+	commands       *CommandRegistry
+	classifier     *RuleClassifier
+	prompts        *PromptLibrary
+	llm            LLMProvider
+	config         *config.Config
+	conversation   *Conversation
+	cache          *storage.ResponseCache
+	usage          *storage.UsageStore
+	sessionUsage   storage.TokenUsage
+	user           string
 	httpClient     *http.Client
 	customSafeDirs []string
 	callUsername   string 
 	callToken      string 
+	results        []interface{}
+	locale         Locale
+	remote         bool
+	history        []storage.HistoryEntry
 }
 
 func NewInterpreter(historyRepo *storage.HistoryRepository) *Interpreter {
 	return &Interpreter{
 		variables:      make(map[string]interface{}),
 		historyRepo:    historyRepo,
+		commands:       defaultRegistry,
+		classifier:     defaultClassifier,
+		prompts:        defaultPrompts,
+		llm:            defaultLLMProvider,
+		config:         config.Default(),
+		conversation:   NewConversation(config.Default().LLM.ConversationTokens),
 		httpClient:     &http.Client{Timeout: 60 * time.Second},
 		customSafeDirs: []string{},
 		callUsername:   "",
 		callToken:      "",
+		locale:         DefaultLocale,
 	}
 }
 
-func (i *Interpreter) handleCallLogin(input string) (interface{}, error) {
+func (i *Interpreter) SetRemote(remote bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remote = remote
+}
+
+func (i *Interpreter) isRemote() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.remote
+}
+
+func (i *Interpreter) handleCallLogin(ctx context.Context, input string) (interface{}, error) {
 	parts := strings.SplitN(input, " ", 3)
 	if len(parts) < 3 {
-		return nil, fmt.Errorf("неверный формат команды. Используйте: войти как [имя]")
+		return nil, i.errorf("call.login_usage")
 	}
 
 	username := parts[2]
-	token, err := i.loginToCallServer(username)
+	token, err := i.loginToCallServer(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
+	i.mu.Lock()
 	i.callUsername = username
 	i.callToken = token
+	i.mu.Unlock()
 
-	return fmt.Sprintf("Успешно вошли как %s. Теперь можете звонить!", username), nil
+	return i.sprintf("call.logged_in", username), nil
 }
-func (i *Interpreter) handleCallCommand(input string) (interface{}, error) {
-	if i.callToken == "" {
-		return nil, fmt.Errorf("сначала выполните вход с помощью команды 'войти как [имя]'")
+func (i *Interpreter) handleCallCommand(ctx context.Context, input string) (interface{}, error) {
+	i.mu.RLock()
+	callUsername, callToken := i.callUsername, i.callToken
+	i.mu.RUnlock()
+
+	if callToken == "" {
+		return nil, i.errorf("call.login_required")
 	}
 
 	parts := strings.SplitN(input, " ", 2)
 	if len(parts) < 2 {
-		return nil, fmt.Errorf("неверный формат команды. Используйте: позвонить [имя]")
+		return nil, i.errorf("call.usage")
 	}
 
 	target := parts[1]
 
-	fmt.Printf("=== DEBUG handleCallCommand ===\n")
-	fmt.Printf("i.callUsername: '%s'\n", i.callUsername)
-	fmt.Printf("target: '%s'\n", target)
 	callerDataID := fmt.Sprintf("caller_%d", time.Now().UnixNano())
 	targetDataID := fmt.Sprintf("target_%d", time.Now().UnixNano())
 	tempDir := os.TempDir()
-	fmt.Printf("Временная директория: %s\n", tempDir)
 	callerData := map[string]string{
-		"token":    i.callToken,
-		"username": i.callUsername,
+		"token":    callToken,
+		"username": callUsername,
 		"target":   target,
 		"autoCall": "true",
 	}
@@ -95,30 +140,19 @@ func (i *Interpreter) handleCallCommand(input string) (interface{}, error) {
 	callerDataFile := filepath.Join(tempDir, callerDataID+".json")
 	callerDataJSON, _ := json.Marshal(callerData)
 
-	fmt.Printf("Создаю файл: %s\n", callerDataFile)
-	fmt.Printf("Данные caller: %s\n", string(callerDataJSON))
-
 	if err := os.WriteFile(callerDataFile, callerDataJSON, 0644); err != nil {
-		fmt.Printf("ОШИБКА записи файла caller: %v\n", err)
-		return nil, fmt.Errorf("ошибка сохранения данных: %v", err)
+		return nil, i.errorf("call.save_failed", err)
 	}
 
-	if _, err := os.Stat(callerDataFile); err == nil {
-		fmt.Printf("Файл caller успешно создан\n")
-	} else {
-		fmt.Printf("ОШИБКА: файл caller не создан: %v\n", err)
-	}
-
-	url1 := fmt.Sprintf("http://localhost:8080/?dataId=%s", callerDataID)
-	fmt.Printf("URL 1: %s\n", url1)
-	_, _, err := i.openBrowser(url1)
+	url1 := fmt.Sprintf("%s/?dataId=%s", i.callServerURL(), callerDataID)
+	_, _, err := i.openBrowser(ctx, url1)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при открытии браузера: %v", err)
+		return nil, i.errorf("browser.open_failed", err)
 	}
 
-	targetToken, err := i.loginToCallServer(target)
+	targetToken, err := i.loginToCallServer(ctx, target)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка входа для %s: %v", target, err)
+		return nil, i.errorf("call.target_login_failed", target, err)
 	}
 
 	targetData := map[string]string{
@@ -128,141 +162,109 @@ func (i *Interpreter) handleCallCommand(input string) (interface{}, error) {
 
 	targetDataFile := filepath.Join(tempDir, targetDataID+".json")
 	targetDataJSON, _ := json.Marshal(targetData)
-	fmt.Printf("Создаю файл: %s\n", targetDataFile)
-	fmt.Printf("Данные target: %s\n", string(targetDataJSON))
 	if err := os.WriteFile(targetDataFile, targetDataJSON, 0644); err != nil {
-		fmt.Printf("ОШИБКА записи файла target: %v\n", err)
-		return nil, fmt.Errorf("ошибка сохранения данных: %v", err)
-	}
-	if _, err := os.Stat(targetDataFile); err == nil {
-		fmt.Printf("Файл target успешно создан\n")
-	} else {
-		fmt.Printf("ОШИБКА: файл target не создан: %v\n", err)
+		return nil, i.errorf("call.save_failed", err)
 	}
 
-	time.Sleep(2 * time.Second)
-	url2 := fmt.Sprintf("http://localhost:8080/?dataId=%s", targetDataID)
-	fmt.Printf("URL 2: %s\n", url2)
-	fmt.Printf("=== END DEBUG ===\n")
-	_, _, err = i.openBrowser(url2)
+	select {
+	case <-time.After(2 * time.Second):
+	case <-ctx.Done():
+		return nil, i.errorf("call.cancelled", ctx.Err())
+	}
+	url2 := fmt.Sprintf("%s/?dataId=%s", i.callServerURL(), targetDataID)
+	_, _, err = i.openBrowser(ctx, url2)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при открытии второго браузера: %v", err)
+		return nil, i.errorf("call.second_browser_failed", err)
 	}
-	return fmt.Sprintf("Открываю звонок: %s звонит %s", i.callUsername, target), nil
+	return i.sprintf("call.started", callUsername, target), nil
 }
 
-func (i *Interpreter) loginToCallServer(username string) (string, error) {
+func (i *Interpreter) loginToCallServer(ctx context.Context, username string) (string, error) {
 	data := map[string]string{"username": username}
 	jsonData, _ := json.Marshal(data)
-	resp, err := http.Post("http://localhost:8080/api/auth/login", "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", i.callServerURL()+"/api/auth/login", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", i.errorf("request.create_failed", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("ошибка подключения к серверу звонков: %v", err)
+		return "", i.errorf("call.server_unavailable", err)
 	}
 	defer resp.Body.Close()
 	var result map[string]string
 	json.NewDecoder(resp.Body).Decode(&result)
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ошибка входа: %s", result)
+		return "", i.errorf("call.login_failed", result)
 	}
 	return result["token"], nil
 }
 
 func (i *Interpreter) Execute(input string) (interface{}, error) {
-	i.historyRepo.AddCommand(input)
-	input = strings.TrimSpace(input)
-	if input == "history" || input == "история" {
-		return i.GetHistory(), nil
-	}
-	if strings.HasPrefix(input, "войти как ") {
-		return i.handleCallLogin(input)
-	}
+	return i.ExecuteContext(context.Background(), input)
+}
 
-	if strings.HasPrefix(input, "позвонить ") {
-		return i.handleCallCommand(input)
-	}
-	if strings.HasPrefix(strings.ToLower(input), "curl ") {
-		return i.handleCurlCommand(input)
+func (i *Interpreter) ExecuteContext(ctx context.Context, input string) (interface{}, error) {
+	result, err := i.ExecuteResult(ctx, input)
+	if err != nil {
+		return nil, err
 	}
+	return result.Value, nil
+}
 
-	if strings.Contains(input, "=") && !strings.Contains(input, "==") {
-		parts := strings.SplitN(input, "=", 2)
-		if len(parts) == 2 {
-			variable := strings.TrimSpace(parts[0])
-			expression := strings.TrimSpace(parts[1])
-
-			if isValidVariableName(variable) {
-				if strings.HasPrefix(strings.ToLower(expression), "curl ") {
-					return i.handleCurlAssignment(variable, expression)
-				}
-			}
-		}
+func (i *Interpreter) ExecuteResult(ctx context.Context, input string) (*Result, error) {
+	start := time.Now()
+	result, err := i.execute(ctx, strings.TrimSpace(input))
+	if err != nil {
+		i.addHistory(input, "")
+		return nil, err
 	}
 
-	if strings.Contains(input, "http://") || strings.Contains(input, "https://") || strings.Contains(input, "www.") {
-		if result, handled, err := i.handleApplicationOpening(input); handled {
-			return result, err
-		}
-		if result, err := i.handleComplexRequest(input); err == nil {
-			return result, nil
-		}
+	result.Duration = time.Since(start)
+	result.Text = i.formatValue(result.Value)
+	if result.Expression != "" {
+		result.Text = result.Expression + " = " + result.Text
 	}
-
-	if i.isCalculableExpression(input) {
-		result, err := i.evaluateExpression(input)
-		if err == nil {
-			return result, nil
-		}
-		return nil, err
+	if result.Value != nil && !result.volatile && (result.Kind == ResultNumber || result.Kind == ResultBool) {
+		i.mu.Lock()
+		i.results = append(i.results, result.Value)
+		result.Ref = fmt.Sprintf("_%d", len(i.results))
+		i.mu.Unlock()
+		i.addHistory(input, result.Text)
+	} else {
+		i.addHistory(input, "")
 	}
 
-	if result, handled, err := i.handleApplicationOpening(input); handled {
-		return result, err
-	}
+	return result, nil
+}
 
-	textResponse, err := i.SendTextToDeepSeek(input)
-	if err != nil {
-		return nil, err
-	}
-	return textResponse, nil
+func (i *Interpreter) execute(ctx context.Context, input string) (*Result, error) {
+	return i.dispatch(ctx, input)
 }
-func (i *Interpreter) isSimpleFileOpenRequest(input string) bool {
-	lowerInput := strings.ToLower(input)
-	simpleCommands := []string{
-		"открой файл", "open file",
-		"включи видео", "play video",
-		"включи музыку", "play music",
-		"открой документ", "open document",
-	}
 
-	for _, cmd := range simpleCommands {
-		if strings.Contains(lowerInput, cmd) && i.containsFileExtension(input) {
-			return true
-		}
-	}
-	return false
+func (i *Interpreter) isSimpleFileOpenRequest(input string) bool {
+	return i.containsKeyword(strings.ToLower(input), "simple_open") && i.containsFileExtension(input)
 }
 
-func (i *Interpreter) handleApplicationOpening(input string) (interface{}, bool, error) {
-	lowerInput := strings.ToLower(input)
-	if strings.Contains(lowerInput, "расскажи") || strings.Contains(lowerInput, "проанализируй") ||
-		strings.Contains(lowerInput, "дай сводку") || strings.Contains(lowerInput, "что на") {
+func (i *Interpreter) handleApplicationOpening(ctx context.Context, input string) (interface{}, bool, error) {
+	if i.containsKeyword(strings.ToLower(input), "analysis") {
 		return nil, false, nil
 	}
 	if i.containsFileExtension(input) {
-		if result, handled, err := i.tryOpenFile(input); handled {
+		if result, handled, err := i.tryOpenFile(ctx, input); handled {
 			return result, handled, err
 		}
 	}
 	if i.isOpenMediaPlayerRequest(input) {
-		return i.openMediaPlayer(input)
+		return i.openMediaPlayer(ctx, input)
 	}
 
 	if i.isOpenFileRequest(input) {
-		return i.openFile(input)
+		return i.openFile(ctx, input)
 	}
 
 	if i.isOpenBrowserRequest(input) {
-		return i.openBrowser(input)
+		return i.openBrowser(ctx, input)
 	}
 	return nil, false, nil
 }
@@ -284,38 +286,29 @@ func (i *Interpreter) containsFileExtension(input string) bool {
 	return false
 }
 
-func (i *Interpreter) tryOpenFile(input string) (interface{}, bool, error) {
+func (i *Interpreter) tryOpenFile(ctx context.Context, input string) (interface{}, bool, error) {
 	filename := i.extractFilenameFromInput(input)
 	if filename == "" {
 		return nil, false, nil
 	}
-	filePath, err := i.findFileInSafeDirectories(filename)
+	filePath, err := i.findFileInSafeDirectories(ctx, filename)
 	if err != nil {
-		return nil, true, fmt.Errorf("файл '%s' не найден в безопасных директориях", filename)
+		return nil, true, i.errorf("file.not_found", filename)
 	}
 	return i.openFileWithDefaultApp(filePath)
 }
 
 func (i *Interpreter) isOpenBrowserRequest(input string) bool {
 	lowerInput := strings.ToLower(input)
-	analysisKeywords := []string{"расскажи", "проанализируй", "дай сводку", "анализ", "что на", "summary", "analyze"}
-	for _, keyword := range analysisKeywords {
-		if strings.Contains(lowerInput, keyword) {
-			return false
-		}
+	if i.containsKeyword(lowerInput, "analysis") {
+		return false
 	}
-	browserKeywords := []string{"открой сайт", "open website", "открой в браузере", "open in browser", "зайди на", "go to", "посети", "visit"}
 	if strings.Contains(lowerInput, "http://") || strings.Contains(lowerInput, "https://") ||
 		strings.Contains(lowerInput, "www.") || i.containsCommonDomain(lowerInput) {
 		return true
 	}
 
-	for _, keyword := range browserKeywords {
-		if strings.Contains(lowerInput, keyword) {
-			return true
-		}
-	}
-	return false
+	return i.containsKeyword(lowerInput, "browser")
 }
 
 func (i *Interpreter) containsCommonDomain(input string) bool {
@@ -330,10 +323,13 @@ func (i *Interpreter) containsCommonDomain(input string) bool {
 
 func (i *Interpreter) isOpenMediaPlayerRequest(input string) bool {
 	lowerInput := strings.ToLower(input)
-	mediaKeywords := []string{"видео", "video", "музыка", "music", "фильм", "movie", "vlc", "проигрыватель", "player", "mp4", "avi", "mkv", "mp3", "wav", "включи", "play"}
+	if i.containsKeyword(lowerInput, "media") {
+		return true
+	}
 
-	for _, keyword := range mediaKeywords {
-		if strings.Contains(lowerInput, keyword) {
+	mediaFormats := []string{"vlc", "mp4", "avi", "mkv", "mp3", "wav"}
+	for _, format := range mediaFormats {
+		if strings.Contains(lowerInput, format) {
 			return true
 		}
 	}
@@ -342,8 +338,7 @@ func (i *Interpreter) isOpenMediaPlayerRequest(input string) bool {
 func (i *Interpreter) isOpenFileRequest(input string) bool {
 	lowerInput := strings.ToLower(input)
 
-	if strings.Contains(lowerInput, "открой сайт") || strings.Contains(lowerInput, "open website") ||
-		strings.Contains(lowerInput, "открой в браузере") || strings.Contains(lowerInput, "open in browser") {
+	if i.containsKeyword(lowerInput, "browser") {
 		return false
 	}
 
@@ -351,18 +346,22 @@ func (i *Interpreter) isOpenFileRequest(input string) bool {
 		return false
 	}
 
-	fileKeywords := []string{"файл", "file", "открой", "open", "документ", "document", "текст", "text", "pdf", "doc", "docx", "txt"}
 	if i.containsFileExtension(input) {
 		return true
 	}
 
-	for _, keyword := range fileKeywords {
-		if strings.Contains(lowerInput, keyword) {
+	if i.containsKeyword(lowerInput, "file") {
+		return true
+	}
+
+	fileFormats := []string{"pdf", "doc", "docx", "txt"}
+	for _, format := range fileFormats {
+		if strings.Contains(lowerInput, format) {
 			return true
 		}
 	}
 
-	if strings.HasPrefix(lowerInput, "открой ") || strings.HasPrefix(lowerInput, "open ") {
+	if i.hasKeywordPrefix(lowerInput, "open") {
 		words := strings.Fields(lowerInput)
 		for _, word := range words {
 			if strings.Contains(word, ".") && !i.isLikelyDomain(word) {
@@ -374,12 +373,12 @@ func (i *Interpreter) isOpenFileRequest(input string) bool {
 	return false
 }
 
-func (i *Interpreter) openBrowser(input string) (interface{}, bool, error) {
+func (i *Interpreter) openBrowser(ctx context.Context, input string) (interface{}, bool, error) {
 
 	url := i.extractURLFromInput(input)
 	if url == "" {
 
-		classification, err := i.classifyRequest(input)
+		classification, err := i.classifyRequest(ctx, input)
 		if err == nil && classification.URL != "" {
 			url = classification.URL
 		} else {
@@ -389,7 +388,7 @@ func (i *Interpreter) openBrowser(input string) (interface{}, bool, error) {
 	}
 
 	if url == "" {
-		return "Пожалуйста, укажите URL или название сайта для открытия", true, nil
+		return i.msg("browser.url_required"), true, nil
 	}
 
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
@@ -414,24 +413,24 @@ func (i *Interpreter) openBrowser(input string) (interface{}, bool, error) {
 			cmd = exec.Command("xdg-open", url)
 		}
 	default:
-		return nil, true, fmt.Errorf("неподдерживаемая операционная система")
+		return nil, true, i.errorf("os.unsupported")
 	}
 
 	if err := cmd.Start(); err != nil {
-		return nil, true, fmt.Errorf("ошибка при открытии браузера: %v", err)
+		return nil, true, i.errorf("browser.open_failed", err)
 	}
 
-	return fmt.Sprintf("Открываю в браузере: %s", url), true, nil
+	return i.sprintf("browser.opening", url), true, nil
 }
 
-func (i *Interpreter) openMediaPlayer(input string) (interface{}, bool, error) {
+func (i *Interpreter) openMediaPlayer(ctx context.Context, input string) (interface{}, bool, error) {
 	filename := i.extractFilenameFromInput(input)
 	if filename == "" {
-		return "Пожалуйста, укажите название видео или аудио файла", true, nil
+		return i.msg("media.file_required"), true, nil
 	}
-	filePath, err := i.findFileInSafeDirectories(filename)
+	filePath, err := i.findFileInSafeDirectories(ctx, filename)
 	if err != nil {
-		return nil, true, fmt.Errorf("файл не найден в безопасных директориях: %v", err)
+		return nil, true, i.errorf("file.search_failed", err)
 	}
 
 	return i.openMediaPlayerWithFile(filePath)
@@ -460,25 +459,25 @@ func (i *Interpreter) openMediaPlayerWithFile(filePath string) (interface{}, boo
 			cmd = exec.Command("xdg-open", filePath)
 		}
 	default:
-		return nil, true, fmt.Errorf("неподдерживаемая операционная система")
+		return nil, true, i.errorf("os.unsupported")
 	}
 
 	if err := cmd.Start(); err != nil {
-		return nil, true, fmt.Errorf("ошибка при открытии медиаплеера: %v", err)
+		return nil, true, i.errorf("media.open_failed", err)
 	}
 
-	return fmt.Sprintf("Открываю в медиаплеере: %s", filepath.Base(filePath)), true, nil
+	return i.sprintf("media.opening", filepath.Base(filePath)), true, nil
 }
 
-func (i *Interpreter) openFile(input string) (interface{}, bool, error) {
+func (i *Interpreter) openFile(ctx context.Context, input string) (interface{}, bool, error) {
 	filename := i.extractFilenameFromInput(input)
 	if filename == "" {
-		return "Пожалуйста, укажите название файла", true, nil
+		return i.msg("file.name_required"), true, nil
 	}
 
-	filePath, err := i.findFileInSafeDirectories(filename)
+	filePath, err := i.findFileInSafeDirectories(ctx, filename)
 	if err != nil {
-		return nil, true, fmt.Errorf("файл не найден в безопасных директориях: %v", err)
+		return nil, true, i.errorf("file.search_failed", err)
 	}
 
 	return i.openFileWithDefaultApp(filePath)
@@ -494,14 +493,14 @@ func (i *Interpreter) openFileWithDefaultApp(filePath string) (interface{}, bool
 	case "linux":
 		cmd = exec.Command("xdg-open", filePath)
 	default:
-		return nil, true, fmt.Errorf("неподдерживаемая операционная система")
+		return nil, true, i.errorf("os.unsupported")
 	}
 
 	if err := cmd.Start(); err != nil {
-		return nil, true, fmt.Errorf("ошибка при открытии файла: %v", err)
+		return nil, true, i.errorf("file.open_failed", err)
 	}
 
-	return fmt.Sprintf("Открываю файл: %s", filepath.Base(filePath)), true, nil
+	return i.sprintf("file.opening", filepath.Base(filePath)), true, nil
 }
 
 func (i *Interpreter) extractURLFromInput(input string) string {
@@ -593,15 +592,18 @@ func (i *Interpreter) getSafeDirectories() []string {
 			safeDirs = append(safeDirs, filepath.Join(cwd, subdir))
 		}
 	}
-	if len(i.customSafeDirs) > 0 {
-		safeDirs = append(safeDirs, i.customSafeDirs...)
-	}
+	i.mu.RLock()
+	safeDirs = append(safeDirs, i.customSafeDirs...)
+	i.mu.RUnlock()
 
 	return safeDirs
 }
-func (i *Interpreter) findFileInSafeDirectories(filename string) (string, error) {
+func (i *Interpreter) findFileInSafeDirectories(ctx context.Context, filename string) (string, error) {
 	safeDirs := i.getSafeDirectories()
 	for _, dir := range safeDirs {
+		if err := ctx.Err(); err != nil {
+			return "", i.errorf("file.search_cancelled", err)
+		}
 		fullPath := filepath.Join(dir, filename)
 		if _, err := os.Stat(fullPath); err == nil {
 			return fullPath, nil
@@ -609,6 +611,9 @@ func (i *Interpreter) findFileInSafeDirectories(filename string) (string, error)
 	}
 	filenameLower := strings.ToLower(filename)
 	for _, dir := range safeDirs {
+		if err := ctx.Err(); err != nil {
+			return "", i.errorf("file.search_cancelled", err)
+		}
 		files, err := os.ReadDir(dir)
 		if err != nil {
 			continue
@@ -624,6 +629,9 @@ func (i *Interpreter) findFileInSafeDirectories(filename string) (string, error)
 		}
 	}
 	for _, dir := range safeDirs {
+		if err := ctx.Err(); err != nil {
+			return "", i.errorf("file.search_cancelled", err)
+		}
 		files, err := os.ReadDir(dir)
 		if err != nil {
 			continue
@@ -639,11 +647,11 @@ func (i *Interpreter) findFileInSafeDirectories(filename string) (string, error)
 		}
 	}
 
-	return "", fmt.Errorf("файл '%s' не найден в безопасных директориях", filename)
+	return "", i.errorf("file.not_found", filename)
 }
 
-func (i *Interpreter) handleComplexRequest(input string) (interface{}, error) {
-	classification, err := i.classifyRequest(input)
+func (i *Interpreter) handleComplexRequest(ctx context.Context, input string) (interface{}, error) {
+	classification, err := i.classifyRequest(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -651,22 +659,22 @@ func (i *Interpreter) handleComplexRequest(input string) (interface{}, error) {
 	switch classification.Type {
 	case "open_website":
 
-		result, err := i.handleWebsiteOpening(classification, input)
+		result, err := i.handleWebsiteOpening(ctx, classification, input)
 		if err != nil {
 			return nil, err
 		}
 
-		if strings.Contains(strings.ToLower(input), "открой") || strings.Contains(strings.ToLower(input), "open") {
+		if i.containsKeyword(strings.ToLower(input), "open") {
 			go i.openBrowserSilent(classification.URL) 
 		}
 
 		return result, nil
 	case "calculation":
-		return nil, fmt.Errorf("не удалось вычислить выражение")
+		return nil, i.errorf("classify.calculation_failed")
 	case "information":
-		return i.SendTextToDeepSeek(input)
+		return i.SendTextToDeepSeek(ctx, input)
 	default:
-		return nil, fmt.Errorf("неизвестный тип запроса")
+		return nil, i.errorf("classify.unknown_type")
 	}
 }
 func (i *Interpreter) openBrowserSilent(url string) {
@@ -693,197 +701,97 @@ func (i *Interpreter) openBrowserSilent(url string) {
 	cmd.Start()
 }
 
-func (i *Interpreter) classifyRequest(input string) (*RequestClassification, error) {
-	systemPrompt := `Ты - классификатор запросов. Проанализируй запрос пользователя и определи его тип.
-Доступные типы:
-- "open_website" - запрос на открытие сайта и выполнение действий с ним (проанализировать, рассказать, дать сводку и т.д.)
-- "calculation" - математические вычисления  
-- "information" - информационный запрос (объяснение, справка и т.д.)
-
-Особые случаи:
-- Если запрос содержит "открой сайт" и "расскажи/проанализируй/дай сводку" - это "open_website"
-- Если запрос содержит URL и просьбу что-то сделать с содержимым - это "open_website"
-- Если просто "открой URL" без анализа - это не наш случай
-
-Верни ответ в формате JSON с полями: type, url (если есть), action, description.`
-
-	requestData := map[string]interface{}{
-		"model": "deepseek-chat",
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": systemPrompt,
-			},
-			{
-				"role":    "user",
-				"content": input,
-			},
-		},
-		"stream":     false,
-		"max_tokens": 500,
+func (i *Interpreter) classifyRequest(ctx context.Context, input string) (*RequestClassification, error) {
+	local, confident := i.classifyLocally(input)
+	if confident {
+		return local, nil
 	}
 
-	requestBody, err := json.Marshal(requestData)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка создания JSON: %v", err)
+	classification, err := i.classifyWithLLM(ctx, input)
+	if err != nil && local != nil {
+		return local, nil
 	}
+	return classification, err
+}
 
-	proxyURL := "https://deproxy.kchugalinskiy.ru/deeproxy/api/completions"
-	req, err := http.NewRequest("POST", proxyURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth("41-2", "U0dMUjFs")
-
-	resp, err := i.httpClient.Do(req)
+func (i *Interpreter) classifyWithLLM(ctx context.Context, input string) (*RequestClassification, error) {
+	systemPrompt, err := i.renderPrompt(PromptClassify, PromptData{})
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при выполнении запроса классификации: %v", err)
-	}
-	defer resp.Body.Close()
-
-	responseBytes, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ошибка от сервера классификации: %s", resp.Status)
-	}
-
-	var apiResponse struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+		return nil, err
 	}
 
-	err = json.Unmarshal(responseBytes, &apiResponse)
+	response, err := i.chat(ctx, ChatRequest{
+		Purpose: LLMPurposeClassify,
+		Messages: []ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: input},
+		},
+		MaxTokens: 500,
+	}, llmErrorKeys{request: "classify.request_failed", status: "classify.server_error", parse: "classify.parse_response_failed"})
 	if err != nil {
-		return nil, fmt.Errorf("ошибка парсинга JSON ответа классификации: %v", err)
+		return nil, err
 	}
 
-	if len(apiResponse.Choices) == 0 {
-		return nil, fmt.Errorf("пустой ответ от классификатора")
+	if response.Content == "" {
+		return nil, i.errorf("classify.empty_response")
 	}
 
 	var classification RequestClassification
-	jsonStart := strings.Index(apiResponse.Choices[0].Message.Content, "{")
-	jsonEnd := strings.LastIndex(apiResponse.Choices[0].Message.Content, "}") + 1
+	jsonStart := strings.Index(response.Content, "{")
+	jsonEnd := strings.LastIndex(response.Content, "}") + 1
 
 	if jsonStart == -1 || jsonEnd == 0 {
-		return nil, fmt.Errorf("не удалось найти JSON в ответе классификатора")
+		return nil, i.errorf("classify.json_not_found")
 	}
 
-	jsonStr := apiResponse.Choices[0].Message.Content[jsonStart:jsonEnd]
+	jsonStr := response.Content[jsonStart:jsonEnd]
 	err = json.Unmarshal([]byte(jsonStr), &classification)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка парсинга классификации: %v", err)
+		return nil, i.errorf("classify.parse_failed", err)
 	}
 
+	classification.Source = "llm"
 	return &classification, nil
 }
 
-func (i *Interpreter) handleWebsiteOpening(classification *RequestClassification, originalRequest string) (string, error) {
+func (i *Interpreter) handleWebsiteOpening(ctx context.Context, classification *RequestClassification, originalRequest string) (string, error) {
 	if classification.URL == "" {
-		return "", fmt.Errorf("URL не указан в классификации")
-	}
-
-	content, err := i.handleCurlCommand("curl " + classification.URL)
-	if err != nil {
-		return "", fmt.Errorf("ошибка при получении содержимого сайта: %v", err)
-	}
-
-	if len(content) > 10000 {
-		content = content[:10000] + "... [контент обрезан]"
+		return "", i.errorf("website.url_missing")
 	}
 
-	return i.analyzeWebsiteContent(content, originalRequest, classification)
-}
-
-func (i *Interpreter) analyzeWebsiteContent(content, originalRequest string, classification *RequestClassification) (string, error) {
-	systemPrompt := `Ты - ассистент для анализа веб-страниц. Проанализируй предоставленное содержимое сайта и выполни задачу пользователя.
-Будь кратким и информативным. Если содержимое слишком большое, сосредоточься на основных моментах.`
-
-	userPrompt := fmt.Sprintf(`Запрос пользователя: "%s"
-
-Содержимое сайта %s:
-%s
-
-Задача: %s`, originalRequest, classification.URL, content, classification.Description)
-
-	requestData := map[string]interface{}{
-		"model": "deepseek-chat",
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": systemPrompt,
-			},
-			{
-				"role":    "user",
-				"content": userPrompt,
-			},
-		},
-		"stream":     false,
-		"max_tokens": 2048,
-	}
-
-	requestBody, err := json.Marshal(requestData)
+	resp, err := i.fetchURL(ctx, classification.URL)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания JSON: %v", err)
+		return "", i.errorf("website.fetch_failed", err)
 	}
 
-	proxyURL := "https://deproxy.kchugalinskiy.ru/deeproxy/api/completions"
-	req, err := http.NewRequest("POST", proxyURL, bytes.NewBuffer(requestBody))
+	answer, err := i.analyzePage(ctx, classification.URL, i.extractPage(resp), originalRequest, classification.Description)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса: %v", err)
+		return "", err
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth("41-2", "U0dMUjFs")
-
-	resp, err := i.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("ошибка при выполнении запроса анализа: %v", err)
-	}
-	defer resp.Body.Close()
-
-	responseBytes, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ошибка от сервера анализа: %s", resp.Status)
-	}
-
-	var apiResponse struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	err = json.Unmarshal(responseBytes, &apiResponse)
-	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга JSON ответа анализа: %v", err)
+	if answer == "" {
+		return i.msg("website.analysis_failed"), nil
 	}
-
-	if len(apiResponse.Choices) > 0 && apiResponse.Choices[0].Message.Content != "" {
-		return fmt.Sprintf("Анализ сайта %s:\n\n%s", classification.URL, apiResponse.Choices[0].Message.Content), nil
-	}
-
-	return "Не удалось проанализировать содержимое сайта", nil
+	return i.sprintf("website.analysis", classification.URL, answer), nil
 }
 
 func (i *Interpreter) isCalculableExpression(input string) bool {
+	calculable, _ := i.checkCalculable(input)
+	return calculable
+}
+
+func (i *Interpreter) checkCalculable(input string) (bool, string) {
 	input = strings.TrimSpace(input)
 
 	if strings.HasPrefix(strings.ToLower(input), "curl ") {
-		return false
+		return false, "explain.calc.curl"
 	}
 
 	if strings.Contains(input, "http://") || strings.Contains(input, "https://") || strings.Contains(input, "www.") {
-		return false
+		return false, "explain.calc.link"
 	}
 
-	if _, exists := i.variables[input]; exists {
-		return true
+	if _, exists := i.lookupVariable(input); exists {
+		return true, "explain.calc.variable"
 	}
 
 	hasMathOps := strings.ContainsAny(input, "+-*/")
@@ -893,7 +801,7 @@ func (i *Interpreter) isCalculableExpression(input string) bool {
 
 	if hasMathOps || hasComparisonOps {
 		if i.containsURL(input) || i.containsFilePath(input) {
-			return false
+			return false, "explain.calc.url_or_path"
 		}
 
 		tokens := i.tokenizeExpression(input)
@@ -904,26 +812,29 @@ func (i *Interpreter) isCalculableExpression(input string) bool {
 			if i.isNumber(token) {
 				hasNumbers = true
 			}
-			if val, exists := i.variables[token]; exists {
+			if val, exists := i.lookupVariable(token); exists {
 				if _, isNumber := val.(float64); isNumber {
 					hasValidVariables = true
 				}
 			}
 		}
 
-		return hasNumbers || hasValidVariables
+		if hasNumbers || hasValidVariables {
+			return true, "explain.calc.operands"
+		}
+		return false, "explain.calc.no_operands"
 	}
 
 	if _, err := strconv.ParseFloat(input, 64); err == nil {
-		return true
+		return true, "explain.calc.number"
 	}
 	if strings.Contains(input, "(") || strings.Contains(input, ")") {
 		if !i.containsURL(input) && !i.containsFilePath(input) {
-			return true
+			return true, "explain.calc.parens"
 		}
 	}
 
-	return false
+	return false, "explain.calc.no_math"
 }
 
 func (i *Interpreter) containsURL(input string) bool {
@@ -937,173 +848,147 @@ func (i *Interpreter) containsFilePath(input string) bool {
 		strings.Contains(input, "file") || strings.Contains(input, "dir"))
 }
 
-func (i *Interpreter) SendTextToDeepSeek(text string) (string, error) {
-	systemPrompt := `Ты - помощник в калькуляторе. Отвечай точно и информативно на вопросы пользователя. 
-Если вопрос требует развернутого ответа - дай его. Используй только проверенные факты и информацию.`
-	requestData := map[string]interface{}{
-		"model": "deepseek-chat",
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": systemPrompt,
-			},
-			{
-				"role":    "user",
-				"content": text,
-			},
-		},
-		"stream":     false,
-		"max_tokens": 2048,
-	}
-
-	requestBody, err := json.Marshal(requestData)
+func (i *Interpreter) SendTextToDeepSeek(ctx context.Context, text string) (string, error) {
+	systemPrompt, err := i.renderPrompt(PromptAnswer, PromptData{})
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания JSON: %v", err)
-	}
-	proxyURL := "https://deproxy.kchugalinskiy.ru/deeproxy/api/completions"
-	req, err := http.NewRequest("POST", proxyURL, bytes.NewBuffer(requestBody))
+		return "", err
+	}
+	response, err := i.chatWithTools(ctx, ChatRequest{
+		Purpose:   LLMPurposeAnswer,
+		Messages:  i.conversationMessages(systemPrompt, text),
+		MaxTokens: 2048,
+		Stream:    true,
+	}, llmErrorKeys{request: "llm.request_failed", status: "llm.server_error", parse: "llm.parse_failed"})
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса: %v", err)
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth("41-2", "U0dMUjFs")
-
-	resp, err := i.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("ошибка при выполнении запроса к DeepSeek: %v", err)
-	}
-	defer resp.Body.Close()
-	responseBytes, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ошибка от сервера: %s, тело ответа: %s", resp.Status, string(responseBytes))
-	}
-	var apiResponse struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	err = json.Unmarshal(responseBytes, &apiResponse)
-	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга JSON ответа: %v", err)
-	}
-
-	if apiResponse.Error.Message != "" {
-		return "", fmt.Errorf("ошибка от API: %s", apiResponse.Error.Message)
+	if response.Content != "" {
+		i.conversation.Add(text, response.Content)
+		return response.Content, nil
 	}
 
-	if len(apiResponse.Choices) > 0 && apiResponse.Choices[0].Message.Content != "" {
-		return apiResponse.Choices[0].Message.Content, nil
-	}
-
-	return "Не удалось получить ответ от AI", nil
+	return i.msg("llm.no_answer"), nil
 }
 
-func (i *Interpreter) handleCurlAssignment(variable string, expression string) (interface{}, error) {
-	result, err := i.handleCurlCommand(expression)
+func (i *Interpreter) handleCurlAssignment(ctx context.Context, variable string, expression string) (interface{}, error) {
+	result, err := i.fetchCurlResponse(ctx, expression)
 	if err != nil {
 		return nil, err
 	}
+	i.mu.Lock()
 	i.variables[variable] = result
-	return fmt.Sprintf("CURL результат сохранен в переменную '%s'", variable), nil
+	i.mu.Unlock()
+	return i.sprintf("curl.saved", variable), nil
 }
 
-func (i *Interpreter) handleCurlCommand(input string) (string, error) {
-	url := strings.TrimSpace(input[5:])
-	if url == "" {
-		return "", fmt.Errorf("curl: отсутствует URL")
-	}
-
-	url = strings.Trim(url, "\"")
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("curl: ошибка создания запроса: %v", err)
-	}
-
-	resp, err := i.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("curl: ошибка выполнения запроса: %v", err)
+func (i *Interpreter) handleAssignment(ctx context.Context, variable string, expression string) (interface{}, error) {
+	if isReservedName(variable) {
+		return nil, i.errorf("calc.reserved_name", variable)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("curl: ошибка чтения ответа: %v", err)
-	}
-
-	return string(body), nil
-}
-
-func (i *Interpreter) handleAssignment(variable string, expression string) (interface{}, error) {
 	if strings.HasPrefix(strings.ToLower(expression), "curl ") {
-		return i.handleCurlAssignment(variable, expression)
+		return i.handleCurlAssignment(ctx, variable, expression)
 	}
 
-	result, err := i.evaluateExpression(expression)
+	result, err := i.evaluateExpression(ctx, expression)
 	if err != nil {
 		return nil, err
 	}
 
+	i.mu.Lock()
 	i.variables[variable] = result
+	i.mu.Unlock()
 	return result, nil
 }
 
-func (i *Interpreter) evaluateExpression(expr string) (interface{}, error) {
+func (i *Interpreter) evaluateExpression(ctx context.Context, expr string) (result interface{}, err error) {
 	expr = strings.TrimSpace(expr)
+	if err := ctx.Err(); err != nil {
+		return nil, i.errorf("calc.cancelled", err)
+	}
 
-	if val, ok := i.variables[expr]; ok {
+	ctx, step := traceStep(ctx, expr, "expression")
+	defer func() { step.finish(i.valueToString(result), err) }()
+
+	if val, ok := i.lookupVariable(expr); ok {
 		return val, nil
 	}
 
+	expanded, pathValue, whole, err := i.expandJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	if whole {
+		return pathValue, nil
+	}
+	if expanded != expr {
+		expr = expanded
+		step.rewrite(expr)
+	}
+
+	if err := i.checkPaths(expr); err != nil {
+		return nil, err
+	}
+
 	if i.containsStringVariables(expr) {
-		return nil, fmt.Errorf("ошибка: выражение содержит строковые переменные, арифметические операции запрещены")
+		return nil, i.errorf("calc.string_variables")
 	}
 
-	expr = i.replaceVariables(expr)
+	if replaced := i.replaceVariables(expr); replaced != expr {
+		expr = replaced
+		step.rewrite(expr)
+	}
 
 	for strings.Contains(expr, "(") {
 		start := strings.LastIndex(expr, "(")
 		end := strings.Index(expr[start:], ")") + start
 
 		if end < start {
-			return nil, fmt.Errorf("непарные скобки")
+			return nil, i.errorf("calc.unbalanced_parens")
 		}
 
-		innerResult, err := i.evaluateExpression(expr[start+1 : end])
+		innerResult, err := i.evaluateExpression(ctx, expr[start+1:end])
 		if err != nil {
 			return nil, err
 		}
 
 		if _, isString := innerResult.(string); isString {
-			return nil, fmt.Errorf("нельзя использовать строки в арифметических операциях")
+			return nil, i.errorf("calc.string_arithmetic")
+		}
+
+		name := functionNameBefore(expr, start)
+		if function, ok := mathFunctions[name]; ok {
+			argument, isNumber := innerResult.(float64)
+			if !isNumber {
+				return nil, i.errorf("calc.function_argument", name)
+			}
+			innerResult = function(argument)
+			if value := innerResult.(float64); math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, i.errorf("calc.function_domain", name, i.valueToString(argument))
+			}
+			start -= len(name)
 		}
 
 		expr = expr[:start] + i.valueToString(innerResult) + expr[end+1:]
+		step.rewrite(expr)
 	}
 
-	if result, handled, err := i.tryComparison(expr); handled {
+	if result, handled, err := i.tryComparison(ctx, expr); handled {
 		return result, err
 	}
 
-	result, err := i.evaluateArithmetic(expr)
+	value, err := i.evaluateArithmetic(ctx, expr)
 	if err != nil {
 		return nil, err 
 	}
 
-	return result, nil
+	return value, nil
 }
 
 func (i *Interpreter) containsStringVariables(expr string) bool {
 	tokens := i.tokenizeExpression(expr)
 
-	for _, token := range tokens {
+	for idx, token := range tokens {
 		token = strings.TrimSpace(token)
 		if token == "" {
 			continue
@@ -1111,11 +996,13 @@ func (i *Interpreter) containsStringVariables(expr string) bool {
 		if i.isOperator(token) || i.isNumber(token) {
 			continue
 		}
-		if token == "true" || token == "false" {
+		if token == "true" || token == "false" || isFunctionCall(tokens, idx) {
 			continue
 		}
-		if val, exists := i.variables[token]; exists {
-			if _, isString := val.(string); isString {
+		if val, exists := i.lookupVariable(token); exists {
+			switch val.(type) {
+			case float64, bool:
+			default:
 				return true
 			}
 		}
@@ -1123,7 +1010,7 @@ func (i *Interpreter) containsStringVariables(expr string) bool {
 	return false
 }
 
-func (i *Interpreter) tryComparison(expr string) (interface{}, bool, error) {
+func (i *Interpreter) tryComparison(ctx context.Context, expr string) (interface{}, bool, error) {
 	ops := []string{">=", "<=", "==", "!=", ">", "<"}
 
 	for _, op := range ops {
@@ -1133,20 +1020,22 @@ func (i *Interpreter) tryComparison(expr string) (interface{}, bool, error) {
 			right := strings.TrimSpace(expr[index+len(op):])
 
 			if i.containsStringVariables(left) || i.containsStringVariables(right) {
-				return false, true, fmt.Errorf("операции сравнения со строковыми переменными запрещены")
+				return false, true, i.errorf("calc.string_comparison")
 			}
 
-			leftVal, err := i.evaluateArithmetic(left)
+			ctx, step := traceStep(ctx, expr, op)
+			leftVal, err := i.evaluateArithmetic(ctx, left)
 			if err != nil {
 				return false, true, err
 			}
 
-			rightVal, err := i.evaluateArithmetic(right)
+			rightVal, err := i.evaluateArithmetic(ctx, right)
 			if err != nil {
 				return false, true, err
 			}
 
 			result, err := i.compareValues(leftVal, rightVal, op)
+			step.finish(i.valueToString(result), err)
 			return result, true, err
 		}
 	}
@@ -1154,8 +1043,14 @@ func (i *Interpreter) tryComparison(expr string) (interface{}, bool, error) {
 	return nil, false, nil
 }
 
-func (i *Interpreter) evaluateArithmetic(expr string) (float64, error) {
+func (i *Interpreter) evaluateArithmetic(ctx context.Context, expr string) (result float64, err error) {
 	expr = strings.TrimSpace(expr)
+	if err := ctx.Err(); err != nil {
+		return 0, i.errorf("calc.cancelled", err)
+	}
+
+	ctx, step := traceStep(ctx, expr, "")
+	defer func() { step.finish(i.valueToString(result), err) }()
 
 	if num, err := strconv.ParseFloat(expr, 64); err == nil {
 		return num, nil
@@ -1164,11 +1059,12 @@ func (i *Interpreter) evaluateArithmetic(expr string) (float64, error) {
 	for idx := len(expr) - 1; idx >= 0; idx-- {
 		if expr[idx] == '+' || expr[idx] == '-' {
 			if idx > 0 && !isArithmeticOperator(rune(expr[idx-1])) {
-				left, err := i.evaluateArithmetic(expr[:idx])
+				step.setOp(string(expr[idx]))
+				left, err := i.evaluateArithmetic(ctx, expr[:idx])
 				if err != nil {
 					return 0, err
 				}
-				right, err := i.evaluateArithmetic(expr[idx+1:])
+				right, err := i.evaluateArithmetic(ctx, expr[idx+1:])
 				if err != nil {
 					return 0, err
 				}
@@ -1184,11 +1080,12 @@ func (i *Interpreter) evaluateArithmetic(expr string) (float64, error) {
 	for idx := len(expr) - 1; idx >= 0; idx-- {
 		if expr[idx] == '*' || expr[idx] == '/' {
 			if idx > 0 && idx < len(expr)-1 {
-				left, err := i.evaluateArithmetic(expr[:idx])
+				step.setOp(string(expr[idx]))
+				left, err := i.evaluateArithmetic(ctx, expr[:idx])
 				if err != nil {
 					return 0, err
 				}
-				right, err := i.evaluateArithmetic(expr[idx+1:])
+				right, err := i.evaluateArithmetic(ctx, expr[idx+1:])
 				if err != nil {
 					return 0, err
 				}
@@ -1197,7 +1094,7 @@ func (i *Interpreter) evaluateArithmetic(expr string) (float64, error) {
 					return left * right, nil
 				} else {
 					if right == 0 {
-						return 0, fmt.Errorf("деление на ноль")
+						return 0, i.errorf("calc.division_by_zero")
 					}
 					return left / right, nil
 				}
@@ -1205,7 +1102,7 @@ func (i *Interpreter) evaluateArithmetic(expr string) (float64, error) {
 		}
 	}
 
-	return 0, fmt.Errorf("неизвестное выражение: %s", expr)
+	return 0, i.errorf("calc.unknown_expression", expr)
 }
 
 func isArithmeticOperator(char rune) bool {
@@ -1227,7 +1124,7 @@ func (i *Interpreter) compareValues(left, right float64, op string) (bool, error
 	case "<=":
 		return left <= right, nil
 	default:
-		return false, fmt.Errorf("неизвестная операция сравнения: %s", op)
+		return false, i.errorf("calc.unknown_comparison", op)
 	}
 }
 
@@ -1242,20 +1139,36 @@ func (i *Interpreter) valueToString(value interface{}) string {
 		return "false"
 	case string:
 		return v
+	case fmt.Stringer:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
 	default:
 		return fmt.Sprintf("%v", v)
 	}
 }
 
+func (i *Interpreter) formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, "\n")
+	default:
+		return i.valueToString(v)
+	}
+}
+
 func (i *Interpreter) replaceVariables(expr string) string {
 	tokens := i.tokenizeExpression(expr)
 
 	for idx, token := range tokens {
-		if i.isOperator(token) || i.isNumber(token) {
+		if i.isOperator(token) || i.isNumber(token) || isFunctionCall(tokens, idx) {
 			continue
 		}
 
-		if val, exists := i.variables[token]; exists {
+		if val, exists := i.lookupVariable(token); exists {
 			tokens[idx] = i.valueToString(val)
 		}
 	}
@@ -1316,8 +1229,142 @@ func (i *Interpreter) isNumber(token string) bool {
 	return err == nil
 }
 
+func (i *Interpreter) Evaluate(ctx context.Context, expr string) (interface{}, error) {
+	return i.evaluateExpression(ctx, expr)
+}
+
+func (i *Interpreter) addHistory(command, result string) {
+	i.historyRepo.AddEntry(command, result)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.history = append(i.history, storage.HistoryEntry{Command: command, Result: result})
+	if len(i.history) > historySize {
+		i.history = i.history[len(i.history)-historySize:]
+	}
+}
+
 func (i *Interpreter) GetHistory() []string {
-	return i.historyRepo.GetLastCommands(10)
+	entries := i.GetHistoryEntries()
+	commands := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		commands = append(commands, entry.Command)
+	}
+	return commands
+}
+
+func (i *Interpreter) lookupVariable(name string) (interface{}, bool) {
+	if value, ok := i.lookupRoot(name); ok {
+		return value, true
+	}
+	if value, isPath, err := i.resolvePath(name); isPath && err == nil {
+		return value, true
+	}
+	return nil, false
+}
+
+func (i *Interpreter) lookupRoot(name string) (interface{}, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if name == "ans" || name == "_" {
+		if len(i.results) == 0 {
+			return nil, false
+		}
+		return i.results[len(i.results)-1], true
+	}
+
+	if val, exists := i.variables[name]; exists {
+		return val, true
+	}
+
+	if strings.HasPrefix(name, "_") {
+		n, err := strconv.Atoi(name[1:])
+		if err != nil || n < 1 || n > len(i.results) {
+			return nil, false
+		}
+		return i.results[n-1], true
+	}
+
+	return nil, false
+}
+
+func (i *Interpreter) ResultCount() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.results)
+}
+
+func (i *Interpreter) GetHistoryEntries() []storage.HistoryEntry {
+	i.mu.RLock()
+	remote := i.remote
+	entries := make([]storage.HistoryEntry, len(i.history))
+	copy(entries, i.history)
+	i.mu.RUnlock()
+
+	if !remote {
+		return i.historyRepo.GetLastEntries(historySize)
+	}
+	return entries
+}
+
+func (i *Interpreter) VariableNames() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	names := make([]string, 0, len(i.variables))
+	for name := range i.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var mathFunctions = map[string]func(float64) float64{
+	"abs":   math.Abs,
+	"ceil":  math.Ceil,
+	"cos":   math.Cos,
+	"exp":   math.Exp,
+	"floor": math.Floor,
+	"ln":    math.Log,
+	"log":   math.Log10,
+	"round": math.Round,
+	"sin":   math.Sin,
+	"sqrt":  math.Sqrt,
+	"tan":   math.Tan,
+}
+
+func (i *Interpreter) FunctionNames() []string {
+	names := make([]string, 0, len(mathFunctions)+1)
+	for name := range mathFunctions {
+		names = append(names, name+"(")
+	}
+	names = append(names, "jsonpath(")
+	sort.Strings(names)
+	return names
+}
+
+func functionNameBefore(expr string, start int) string {
+	begin := start
+	for begin > 0 {
+		r, size := utf8.DecodeLastRuneInString(expr[:begin])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		begin -= size
+	}
+	return expr[begin:start]
+}
+
+func isFunctionCall(tokens []string, idx int) bool {
+	if _, ok := mathFunctions[tokens[idx]]; !ok {
+		return false
+	}
+	return idx+1 < len(tokens) && strings.TrimSpace(tokens[idx+1]) == "("
+}
+
+func isReservedName(name string) bool {
+	return name == "ans"
 }
 
 func isValidVariableName(name string) bool {
@@ -1335,13 +1382,15 @@ func isValidVariableName(name string) bool {
 func (i *Interpreter) AddSafeDirectory(dir string) (string, error) {
 	absPath, err := filepath.Abs(dir)
 	if err != nil {
-		return "", fmt.Errorf("ошибка получения абсолютного пути: %v", err)
+		return "", i.errorf("safedir.abs_failed", err)
 	}
 
 	if _, err := os.Stat(absPath); err != nil {
-		return "", fmt.Errorf("директория не существует: %s", absPath)
+		return "", i.errorf("safedir.missing", absPath)
 	}
 
+	i.mu.Lock()
 	i.customSafeDirs = append(i.customSafeDirs, absPath)
-	return fmt.Sprintf("Директория добавлена в безопасный список: %s", absPath), nil
+	i.mu.Unlock()
+	return i.sprintf("safedir.added", absPath), nil
 }
@@ -0,0 +1,747 @@
+package business
+
+import (
+	"bufio"
+	"bytes"
+	"calculator/config"
+	"calculator/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LLMProviderOpenAI = "openai"
+	LLMProviderOllama = "ollama"
+	LLMProviderMock   = "mock"
+)
+
+const (
+	LLMPurposeClassify  = "classify"
+	LLMPurposeAnalyze   = "analyze"
+	LLMPurposeAnswer    = "answer"
+	LLMPurposeTranslate = "translate"
+	LLMPurposeSummarize = "summarize"
+)
+
+type ChatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+}
+
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type ChatRequest struct {
+	Purpose   string
+	Messages  []ChatMessage
+	Tools     []Tool
+	MaxTokens int
+	Stream    bool
+}
+
+type ChatResponse struct {
+	Content   string
+	Model     string
+	ToolCalls []ToolCall
+	Usage     storage.TokenUsage
+}
+
+type LLMProvider interface {
+	Name() string
+	ModelName() string
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+}
+
+type StreamingLLMProvider interface {
+	LLMProvider
+	ChatStream(ctx context.Context, req ChatRequest, onDelta func(delta string)) (*ChatResponse, error)
+}
+
+type tokenStreamKey struct{}
+
+func WithTokenStream(ctx context.Context, onDelta func(delta string)) context.Context {
+	return context.WithValue(ctx, tokenStreamKey{}, onDelta)
+}
+
+func tokenStream(ctx context.Context) func(delta string) {
+	onDelta, _ := ctx.Value(tokenStreamKey{}).(func(delta string))
+	return onDelta
+}
+
+func NewLLMProvider(config config.LLM) (LLMProvider, error) {
+	timeout := config.Timeout.Duration
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+
+	retry := RetryPolicy{
+		MaxRetries: config.MaxRetries,
+		BaseDelay:  config.RetryDelay.Duration,
+		MaxDelay:   config.RetryMaxDelay.Duration,
+	}
+	breaker := NewCircuitBreaker(config.BreakerThreshold, config.BreakerMinRequests, config.BreakerCooldown.Duration)
+	client := &llmClient{http: &http.Client{Timeout: timeout}, retry: retry, breaker: breaker}
+	streamClient := &llmClient{http: &http.Client{Transport: transport}, retry: retry, breaker: breaker}
+
+	switch strings.ToLower(config.Provider) {
+	case "", LLMProviderOpenAI:
+		if config.Endpoint == "" {
+			return nil, fmt.Errorf("для провайдера %s нужен адрес сервера", LLMProviderOpenAI)
+		}
+		return &OpenAIProvider{
+			Endpoint:     config.Endpoint,
+			Model:        config.Model,
+			APIKey:       config.APIKey,
+			Username:     config.Username,
+			Password:     config.Password,
+			client:       client,
+			streamClient: streamClient,
+		}, nil
+	case LLMProviderOllama:
+		endpoint := config.Endpoint
+		if endpoint == "" {
+			endpoint = "http://localhost:11434/api/chat"
+		}
+		return &OllamaProvider{Endpoint: endpoint, Model: config.Model, client: client, streamClient: streamClient}, nil
+	case LLMProviderMock:
+		return NewMockProvider(), nil
+	default:
+		return nil, fmt.Errorf("неизвестный провайдер LLM '%s'", config.Provider)
+	}
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u openAIUsage) tokenUsage() storage.TokenUsage {
+	return storage.TokenUsage{Requests: 1, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+type LLMErrorKind int
+
+const (
+	LLMErrorRequest LLMErrorKind = iota
+	LLMErrorStatus
+	LLMErrorParse
+	LLMErrorAPI
+	LLMErrorUnavailable
+)
+
+type LLMError struct {
+	Kind    LLMErrorKind
+	Status  string
+	Body    string
+	Err     error
+	RetryIn time.Duration
+}
+
+func (e *LLMError) Error() string {
+	switch e.Kind {
+	case LLMErrorStatus:
+		return fmt.Sprintf("%s: %s", e.Status, e.Body)
+	case LLMErrorAPI:
+		return e.Body
+	case LLMErrorUnavailable:
+		return fmt.Sprintf("circuit breaker open, retry in %s", e.RetryIn)
+	default:
+		return e.Err.Error()
+	}
+}
+
+func (e *LLMError) Unwrap() error {
+	return e.Err
+}
+
+type OpenAIProvider struct {
+	Endpoint     string
+	Model        string
+	APIKey       string
+	Username     string
+	Password     string
+	client       *llmClient
+	streamClient *llmClient
+}
+
+func (p *OpenAIProvider) Name() string {
+	return LLMProviderOpenAI
+}
+
+func (p *OpenAIProvider) ModelName() string {
+	return p.Model
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	requestData := map[string]interface{}{
+		"model":      p.Model,
+		"messages":   chatReq.Messages,
+		"stream":     false,
+		"max_tokens": chatReq.MaxTokens,
+	}
+	if len(chatReq.Tools) > 0 {
+		requestData["tools"] = chatReq.Tools
+	}
+
+	responseBytes, err := postLLMRequest(ctx, p.client, p.Endpoint, requestData, p.authorize)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResponse struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content   string     `json:"content"`
+				ToolCalls []ToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage openAIUsage `json:"usage"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(responseBytes, &apiResponse); err != nil {
+		return nil, &LLMError{Kind: LLMErrorParse, Err: err}
+	}
+	if apiResponse.Error.Message != "" {
+		return nil, &LLMError{Kind: LLMErrorAPI, Body: apiResponse.Error.Message}
+	}
+
+	response := &ChatResponse{Model: apiResponse.Model, Usage: apiResponse.Usage.tokenUsage()}
+	if len(apiResponse.Choices) > 0 {
+		response.Content = apiResponse.Choices[0].Message.Content
+		response.ToolCalls = apiResponse.Choices[0].Message.ToolCalls
+	}
+	return response, nil
+}
+
+func (p *OpenAIProvider) ChatStream(ctx context.Context, chatReq ChatRequest, onDelta func(delta string)) (*ChatResponse, error) {
+	requestData := map[string]interface{}{
+		"model":      p.Model,
+		"messages":   chatReq.Messages,
+		"stream":     true,
+		"max_tokens": chatReq.MaxTokens,
+
+		"stream_options": map[string]bool{"include_usage": true},
+	}
+	if len(chatReq.Tools) > 0 {
+		requestData["tools"] = chatReq.Tools
+	}
+
+	body, err := openLLMStream(ctx, p.streamClient, p.Endpoint, requestData, p.authorize)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var content strings.Builder
+	response := &ChatResponse{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Index int `json:"index"`
+						ToolCall
+					} `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, &LLMError{Kind: LLMErrorParse, Err: err}
+		}
+		if chunk.Error.Message != "" {
+			return nil, &LLMError{Kind: LLMErrorAPI, Body: chunk.Error.Message}
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			response.Usage = chunk.Usage.tokenUsage()
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			onDelta(delta.Content)
+		}
+		for _, part := range delta.ToolCalls {
+			for len(response.ToolCalls) <= part.Index {
+				response.ToolCalls = append(response.ToolCalls, ToolCall{Type: "function"})
+			}
+			call := &response.ToolCalls[part.Index]
+			if part.ID != "" {
+				call.ID = part.ID
+			}
+			call.Function.Name += part.Function.Name
+			call.Function.Arguments += part.Function.Arguments
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+	}
+
+	response.Content = content.String()
+	return response, nil
+}
+
+func (p *OpenAIProvider) authorize(req *http.Request) {
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	} else if p.Username != "" {
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+}
+
+type OllamaProvider struct {
+	Endpoint     string
+	Model        string
+	client       *llmClient
+	streamClient *llmClient
+}
+
+func (p *OllamaProvider) Name() string {
+	return LLMProviderOllama
+}
+
+func (p *OllamaProvider) ModelName() string {
+	return p.Model
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	requestData := map[string]interface{}{
+		"model":    p.Model,
+		"messages": ollamaMessages(chatReq.Messages),
+		"stream":   false,
+	}
+	if chatReq.MaxTokens > 0 {
+		requestData["options"] = map[string]int{"num_predict": chatReq.MaxTokens}
+	}
+	if len(chatReq.Tools) > 0 {
+		requestData["tools"] = chatReq.Tools
+	}
+
+	responseBytes, err := postLLMRequest(ctx, p.client, p.Endpoint, requestData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResponse struct {
+		Model   string        `json:"model"`
+		Message ollamaMessage `json:"message"`
+		Error   string        `json:"error"`
+		ollamaUsage
+	}
+	if err := json.Unmarshal(responseBytes, &apiResponse); err != nil {
+		return nil, &LLMError{Kind: LLMErrorParse, Err: err}
+	}
+	if apiResponse.Error != "" {
+		return nil, &LLMError{Kind: LLMErrorAPI, Body: apiResponse.Error}
+	}
+	return &ChatResponse{
+		Content:   apiResponse.Message.Content,
+		Model:     apiResponse.Model,
+		ToolCalls: apiResponse.Message.toolCalls(),
+		Usage:     apiResponse.tokenUsage(),
+	}, nil
+}
+
+func (p *OllamaProvider) ChatStream(ctx context.Context, chatReq ChatRequest, onDelta func(delta string)) (*ChatResponse, error) {
+	requestData := map[string]interface{}{
+		"model":    p.Model,
+		"messages": ollamaMessages(chatReq.Messages),
+		"stream":   true,
+	}
+	if chatReq.MaxTokens > 0 {
+		requestData["options"] = map[string]int{"num_predict": chatReq.MaxTokens}
+	}
+	if len(chatReq.Tools) > 0 {
+		requestData["tools"] = chatReq.Tools
+	}
+
+	body, err := openLLMStream(ctx, p.streamClient, p.Endpoint, requestData, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var content strings.Builder
+	response := &ChatResponse{}
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk struct {
+			Model   string        `json:"model"`
+			Message ollamaMessage `json:"message"`
+			Done    bool          `json:"done"`
+			Error   string        `json:"error"`
+			ollamaUsage
+		}
+		err := decoder.Decode(&chunk)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, &LLMError{Kind: LLMErrorRequest, Err: ctxErr}
+			}
+			return nil, &LLMError{Kind: LLMErrorParse, Err: err}
+		}
+		if chunk.Error != "" {
+			return nil, &LLMError{Kind: LLMErrorAPI, Body: chunk.Error}
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			onDelta(chunk.Message.Content)
+		}
+		response.ToolCalls = append(response.ToolCalls, chunk.Message.toolCalls()...)
+		if chunk.Done {
+			response.Usage = chunk.tokenUsage()
+			break
+		}
+	}
+
+	response.Content = content.String()
+	return response, nil
+}
+
+type ollamaUsage struct {
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (u ollamaUsage) tokenUsage() storage.TokenUsage {
+	return storage.TokenUsage{Requests: 1, PromptTokens: u.PromptEvalCount, CompletionTokens: u.EvalCount}
+}
+
+type ollamaMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Function struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls,omitempty"`
+}
+
+func (m ollamaMessage) toolCalls() []ToolCall {
+	var calls []ToolCall
+	for idx, call := range m.ToolCalls {
+		calls = append(calls, ToolCall{
+			ID:   fmt.Sprintf("call_%d", idx),
+			Type: "function",
+			Function: ToolCallFunction{
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			},
+		})
+	}
+	return calls
+}
+
+func ollamaMessages(messages []ChatMessage) []map[string]interface{} {
+	converted := make([]map[string]interface{}, 0, len(messages))
+	for _, message := range messages {
+		item := map[string]interface{}{"role": message.Role, "content": message.Content}
+		if len(message.ToolCalls) > 0 {
+			var calls []map[string]interface{}
+			for _, call := range message.ToolCalls {
+				arguments := call.Function.Arguments
+				if strings.TrimSpace(arguments) == "" {
+					arguments = "{}"
+				}
+				calls = append(calls, map[string]interface{}{
+					"function": map[string]interface{}{
+						"name":      call.Function.Name,
+						"arguments": json.RawMessage(arguments),
+					},
+				})
+			}
+			item["tool_calls"] = calls
+		}
+		converted = append(converted, item)
+	}
+	return converted
+}
+
+func postLLMRequest(ctx context.Context, client *llmClient, endpoint string, requestData interface{}, authorize func(*http.Request)) ([]byte, error) {
+	body, err := openLLMStream(ctx, client, endpoint, requestData, authorize)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	responseBytes, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+	}
+	return responseBytes, nil
+}
+
+func openLLMStream(ctx context.Context, client *llmClient, endpoint string, requestData interface{}, authorize func(*http.Request)) (io.ReadCloser, error) {
+	requestBody, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+	}
+
+	if allowed, wait := client.breaker.Allow(); !allowed {
+		return nil, &LLMError{Kind: LLMErrorUnavailable, RetryIn: wait}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(requestBody))
+		if err != nil {
+			client.breaker.Release()
+			return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if authorize != nil {
+			authorize(req)
+		}
+
+		resp, err := client.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				client.breaker.Release()
+				return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+			}
+			if attempt >= client.retry.MaxRetries {
+				client.breaker.Record(false)
+				return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+			}
+			if err := sleepContext(ctx, client.retry.backoff(attempt)); err != nil {
+				client.breaker.Release()
+				return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+			}
+			continue
+		}
+		if resp.StatusCode == http.StatusOK {
+			return &breakerBody{ReadCloser: resp.Body, ctx: ctx, breaker: client.breaker}, nil
+		}
+
+		responseBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		statusErr := &LLMError{Kind: LLMErrorStatus, Status: resp.Status, Body: string(responseBytes)}
+		if !retryableStatus(resp.StatusCode) {
+			client.breaker.Record(false)
+			return nil, statusErr
+		}
+
+		delay, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			delay = client.retry.backoff(attempt)
+		}
+		if attempt >= client.retry.MaxRetries || delay > client.retry.maxDelay() {
+			client.breaker.Record(false)
+			return nil, statusErr
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			client.breaker.Release()
+			return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+		}
+	}
+}
+
+type MockProvider struct {
+	mu       sync.Mutex
+	Reply    func(req ChatRequest) string
+	requests []ChatRequest
+}
+
+func NewMockProvider() *MockProvider {
+	return &MockProvider{}
+}
+
+func (p *MockProvider) Name() string {
+	return LLMProviderMock
+}
+
+func (p *MockProvider) ModelName() string {
+	return LLMProviderMock
+}
+
+func (p *MockProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+	}
+
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	reply := p.Reply
+	p.mu.Unlock()
+
+	if reply == nil {
+		reply = mockReply
+	}
+	return &ChatResponse{Content: reply(req), Model: LLMProviderMock}, nil
+}
+
+func (p *MockProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(delta string)) (*ChatResponse, error) {
+	response, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delta := range strings.SplitAfter(response.Content, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, &LLMError{Kind: LLMErrorRequest, Err: err}
+		}
+		onDelta(delta)
+	}
+	return response, nil
+}
+
+func (p *MockProvider) Requests() []ChatRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	requests := make([]ChatRequest, len(p.requests))
+	copy(requests, p.requests)
+	return requests
+}
+
+func mockReply(req ChatRequest) string {
+	question := lastUserMessage(req.Messages)
+	switch req.Purpose {
+	case LLMPurposeClassify:
+		data, _ := json.Marshal(RequestClassification{Type: "information", Description: question})
+		return string(data)
+	case LLMPurposeTranslate:
+		return `{"expression": ""}`
+	}
+	return "[mock] " + question
+}
+
+func lastUserMessage(messages []ChatMessage) string {
+	for idx := len(messages) - 1; idx >= 0; idx-- {
+		if messages[idx].Role == "user" {
+			return messages[idx].Content
+		}
+	}
+	return ""
+}
+
+var defaultLLMProvider LLMProvider = NewMockProvider()
+
+func (i *Interpreter) SetLLMProvider(provider LLMProvider) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.llm = provider
+}
+
+func (i *Interpreter) LLMProvider() LLMProvider {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.llm
+}
+
+type llmErrorKeys struct {
+	request string
+	status  string
+	parse   string
+}
+
+func (i *Interpreter) chat(ctx context.Context, req ChatRequest, keys llmErrorKeys) (*ChatResponse, error) {
+	var response *ChatResponse
+	var err error
+
+	provider := i.LLMProvider()
+	onDelta := tokenStream(ctx)
+	cache, key := i.responseCache(ctx, provider, req)
+	if cache != nil {
+		if content, ok := cache.Get(key); ok {
+			if req.Stream && onDelta != nil {
+				onDelta(content)
+			}
+			return &ChatResponse{Content: content, Model: provider.ModelName()}, nil
+		}
+	}
+
+	if err := i.checkQuota(); err != nil {
+		return nil, err
+	}
+
+	streaming, canStream := provider.(StreamingLLMProvider)
+	if req.Stream && onDelta != nil && canStream {
+		response, err = streaming.ChatStream(ctx, req, onDelta)
+	} else {
+		response, err = provider.Chat(ctx, req)
+	}
+	if err == nil {
+		i.recordUsage(req, response)
+		if cache != nil && response.Content != "" && len(response.ToolCalls) == 0 {
+			cache.Put(key, response.Content)
+		}
+		return response, nil
+	}
+
+	llmErr, ok := err.(*LLMError)
+	if !ok {
+		return nil, i.errorf(keys.request, err)
+	}
+	switch llmErr.Kind {
+	case LLMErrorStatus:
+		return nil, i.errorf(keys.status, llmErr.Status, llmErr.Body)
+	case LLMErrorParse:
+		return nil, i.errorf(keys.parse, llmErr.Err)
+	case LLMErrorAPI:
+		return nil, i.errorf("llm.api_error", llmErr.Body)
+	case LLMErrorUnavailable:
+		return nil, i.errorf("llm.unavailable", int(llmErr.RetryIn.Seconds()+0.5))
+	default:
+		return nil, i.errorf(keys.request, llmErr.Err)
+	}
+}
@@ -0,0 +1,623 @@
+package business
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Locale string
+
+const (
+	LocaleRu      Locale = "ru"
+	LocaleEn      Locale = "en"
+	DefaultLocale        = LocaleRu
+)
+
+var messages = map[Locale]map[string]string{
+	LocaleRu: {
+		"call.login_usage":               "неверный формат команды. Используйте: войти как [имя]",
+		"call.logged_in":                 "Успешно вошли как %s. Теперь можете звонить!",
+		"call.login_required":            "сначала выполните вход с помощью команды 'войти как [имя]'",
+		"call.usage":                     "неверный формат команды. Используйте: позвонить [имя]",
+		"call.save_failed":               "ошибка сохранения данных: %v",
+		"call.target_login_failed":       "ошибка входа для %s: %v",
+		"call.cancelled":                 "звонок отменен: %v",
+		"call.second_browser_failed":     "ошибка при открытии второго браузера: %v",
+		"call.started":                   "Открываю звонок: %s звонит %s",
+		"call.server_unavailable":        "ошибка подключения к серверу звонков: %v",
+		"call.login_failed":              "ошибка входа: %s",
+		"request.create_failed":          "ошибка создания запроса: %v",
+		"json.create_failed":             "ошибка создания JSON: %v",
+		"os.unsupported":                 "неподдерживаемая операционная система",
+		"browser.open_failed":            "ошибка при открытии браузера: %v",
+		"browser.url_required":           "Пожалуйста, укажите URL или название сайта для открытия",
+		"browser.opening":                "Открываю в браузере: %s",
+		"media.file_required":            "Пожалуйста, укажите название видео или аудио файла",
+		"media.open_failed":              "ошибка при открытии медиаплеера: %v",
+		"media.opening":                  "Открываю в медиаплеере: %s",
+		"file.not_found":                 "файл '%s' не найден в безопасных директориях",
+		"file.search_failed":             "файл не найден в безопасных директориях: %v",
+		"file.search_cancelled":          "поиск файла отменен: %v",
+		"file.name_required":             "Пожалуйста, укажите название файла",
+		"file.open_failed":               "ошибка при открытии файла: %v",
+		"file.opening":                   "Открываю файл: %s",
+		"classify.calculation_failed":    "не удалось вычислить выражение",
+		"classify.unknown_type":          "неизвестный тип запроса",
+		"classify.request_failed":        "ошибка при выполнении запроса классификации: %v",
+		"classify.server_error":          "ошибка от сервера классификации: %s, тело ответа: %s",
+		"classify.parse_response_failed": "ошибка парсинга JSON ответа классификации: %v",
+		"classify.empty_response":        "пустой ответ от классификатора",
+		"classify.json_not_found":        "не удалось найти JSON в ответе классификатора",
+		"classify.parse_failed":          "ошибка парсинга классификации: %v",
+		"website.url_missing":            "URL не указан в классификации",
+		"website.fetch_failed":           "ошибка при получении содержимого сайта: %v",
+		"website.empty_page":             "на странице %s не найден текст для анализа",
+		"website.request_failed":         "ошибка при выполнении запроса анализа: %v",
+		"website.server_error":           "ошибка от сервера анализа: %s, тело ответа: %s",
+		"website.parse_failed":           "ошибка парсинга JSON ответа анализа: %v",
+		"website.analysis":               "Анализ сайта %s:\n\n%s",
+		"website.analysis_failed":        "Не удалось проанализировать содержимое сайта",
+		"llm.request_failed":             "ошибка при выполнении запроса к AI: %v",
+		"llm.server_error":               "ошибка от сервера: %s, тело ответа: %s",
+		"llm.parse_failed":               "ошибка парсинга JSON ответа: %v",
+		"llm.api_error":                  "ошибка от API: %s",
+		"llm.unavailable":                "AI временно недоступен, попробуйте через %d с",
+		"llm.no_answer":                  "Не удалось получить ответ от AI",
+		"curl.saved":                     "CURL результат сохранен в переменную '%s'",
+		"curl.url_missing":               "curl: отсутствует URL",
+		"curl.request_failed":            "curl: ошибка создания запроса: %v",
+		"curl.do_failed":                 "curl: ошибка выполнения запроса: %v",
+		"curl.read_failed":               "curl: ошибка чтения ответа: %v",
+		"curl.scheme_blocked":            "curl: схема %s не поддерживается, используйте http или https",
+		"curl.host_blocked":              "curl: запросы к %s запрещены",
+		"curl.host_not_allowed":          "curl: %s нет в списке разрешённых хостов",
+		"curl.address_blocked":           "curl: адрес %s относится к внутренней сети, запрос запрещён",
+		"curl.content_type_blocked":      "curl: тип содержимого %s не разрешён",
+		"curl.too_large":                 "curl: ответ больше %d байт",
+		"curl.bad_syntax":                "curl: ошибка в командной строке: %v",
+		"curl.unknown_option":            "curl: неизвестный параметр %s",
+		"curl.missing_value":             "curl: параметру %s нужно значение",
+		"curl.extra_argument":            "curl: лишний аргумент '%s' (URL уже указан)",
+		"curl.bad_header":                "curl: некорректный заголовок '%s', ожидается 'Имя: значение'",
+		"curl.bad_max_time":              "curl: некорректное значение --max-time '%s'",
+		"curl.data_file_unsupported":     "curl: чтение данных из файла (%s @файл) не поддерживается",
+		"curl.too_many_redirects":        "слишком много перенаправлений (больше %d)",
+		"curl.output_not_allowed":        "curl: запись в %s запрещена, используйте безопасную директорию",
+		"curl.write_failed":              "curl: ошибка записи файла: %v",
+		"curl.output_exists":             "curl: файл %s уже существует, перезапись запрещена",
+		"curl.output_remote":             "curl: сохранение в файл (-o) доступно только в терминале",
+		"curl.output_saved":              "%d байт сохранено в %s",
+		"calc.cancelled":                 "вычисление отменено: %v",
+		"calc.string_variables":          "ошибка: выражение содержит строковые переменные, арифметические операции запрещены",
+		"calc.unbalanced_parens":         "непарные скобки",
+		"calc.function_argument":         "аргумент функции %s должен быть числом",
+		"calc.function_domain":           "функция %s не определена для %s",
+		"calc.string_arithmetic":         "нельзя использовать строки в арифметических операциях",
+		"calc.reserved_name":             "имя '%s' зарезервировано для последнего результата",
+		"calc.bad_path":                  "не удалось прочитать %s: %v",
+		"calc.jsonpath_syntax":           "jsonpath: %v",
+		"calc.jsonpath_usage":            "использование: jsonpath(переменная, \"$.путь.к[0].полю\")",
+		"calc.jsonpath_source":           "jsonpath: переменная '%s' не найдена",
+		"calc.jsonpath_not_json":         "jsonpath: ответ в '%s' не является JSON",
+		"calc.string_comparison":         "операции сравнения со строковыми переменными запрещены",
+		"calc.division_by_zero":          "деление на ноль",
+		"calc.unknown_expression":        "неизвестное выражение: %s",
+		"calc.unknown_comparison":        "неизвестная операция сравнения: %s",
+		"safedir.abs_failed":             "ошибка получения абсолютного пути: %v",
+		"safedir.missing":                "директория не существует: %s",
+		"safedir.added":                  "Директория добавлена в безопасный список: %s",
+		"dispatch.no_route":              "не удалось определить, как обработать запрос",
+		"help.title":                     "Доступные команды:",
+		"help.help":                      "список доступных команд",
+		"help.history":                   "последние 10 команд",
+		"help.call_login":                "войти на сервер звонков",
+		"help.call":                      "позвонить пользователю",
+		"help.curl":                      "выполнить HTTP запрос (-X, -H, -d, --json, -u, -L, -I, -i, -s, -o, --max-time); x = curl URL сохраняет ответ, поля x.status, x.headers, x.body.a[0]",
+		"help.assignment":                "сохранить результат в переменную",
+		"help.open_link":                 "открыть ссылку в браузере",
+		"help.website_analysis":          "проанализировать содержимое сайта по ссылке",
+		"help.calculation":               "вычислить арифметическое выражение или сравнение",
+		"help.open":                      "открыть файл, медиаплеер или сайт",
+		"help.llm":                       "задать вопрос AI",
+		"help.locale":                    "переключить язык (ru, en)",
+		"args.name":                      "[имя]",
+		"args.url":                       "[параметры] [url]",
+		"args.assignment":                "[имя] = [выражение]",
+		"args.locale":                    "[ru|en]",
+		"locale.changed":                 "Язык переключен: %s",
+		"locale.current":                 "Текущий язык: %s",
+		"locale.unsupported":             "неподдерживаемый язык: %s. Доступны: %s",
+		"ui.result":                      "Результат",
+		"ui.error":                       "Ошибка",
+		"ui.warning":                     "Предупреждение",
+		"ui.ai_answer":                   "Ответ AI",
+		"ui.goodbye":                     "До свидания!",
+		"ui.history_title":               "Последние 10 команд:",
+		"ui.input_error":                 "Ошибка ввода",
+		"help.explain":                   "показать, какой маршрут обработает ввод и как он будет вычислен",
+		"args.input":                     "[ввод]",
+		"explain.input":                  "Ввод",
+		"explain.route":                  "Маршрут",
+		"explain.reason":                 "Причина",
+		"explain.checked":                "Проверенные маршруты",
+		"explain.steps":                  "Шаги вычисления",
+		"explain.fallbacks":              "Если маршрут не справится",
+		"explain.alias":                  "начинается с ключевого слова «%s»",
+		"explain.assignment":             "слева от «=» допустимое имя переменной «%s»",
+		"explain.calc.curl":              "ввод начинается с curl",
+		"explain.calc.link":              "ввод содержит ссылку",
+		"explain.calc.variable":          "ввод совпадает с именем переменной или ссылкой на результат",
+		"explain.calc.url_or_path":       "есть операторы, но ввод похож на URL или путь к файлу",
+		"explain.calc.operands":          "есть арифметические операторы или сравнения и числовые операнды",
+		"explain.calc.no_operands":       "есть операторы, но нет чисел и числовых переменных",
+		"explain.calc.number":            "ввод является числом",
+		"explain.calc.parens":            "ввод содержит скобки",
+		"explain.calc.no_math":           "нет операторов, чисел или скобок",
+		"help.config":                    "показать текущую конфигурацию (секреты скрыты)",
+		"args.config":                    "[показать]",
+		"config.usage":                   "использование: конфиг [показать]",
+		"config.source":                  "Конфигурация (источник: %s):",
+		"config.defaults":                "значения по умолчанию",
+		"config.cli_only":                "конфигурация доступна только в терминале",
+		"help.reset":                     "очистить память разговора с AI",
+		"conversation.state":             "Состояние калькулятора (переменные и последние результаты):",
+		"conversation.summary":           "Краткое содержание более ранней части разговора:",
+		"conversation.reset":             "Память разговора с AI очищена",
+		"tools.error":                    "ошибка: %v",
+		"tools.bad_arguments":            "некорректные аргументы инструмента: %v",
+		"tools.unknown":                  "неизвестный инструмент '%s'",
+		"tools.unknown_variable":         "переменная '%s' не найдена",
+		"tools.bad_variable":             "недопустимое имя переменной '%s'",
+		"tools.no_variables":             "переменных и результатов пока нет",
+		"help.nl_math":                   "перевести задачу на естественном языке в выражение и вычислить его",
+		"explain.nl_math":                "локальный классификатор считает это вычислением (уверенность %.2f)",
+		"nlmath.no_expression":           "AI не смог составить выражение",
+		"nlmath.not_expression":          "«%s» не является выражением калькулятора",
+		"nlmath.eval_failed":             "выражение «%s» не вычисляется: %v",
+		"nlmath.unverified":              "ответ AI не проверен калькулятором: %v",
+		"help.prompts":                   "показать шаблоны системных промптов или перечитать их с диска",
+		"args.prompts":                   "[перезагрузить]",
+		"prompts.usage":                  "использование: промпты [перезагрузить]",
+		"prompts.title":                  "Шаблоны промптов (%s):",
+		"prompts.embedded":               "встроенные",
+		"prompts.line":                   "  %s — версия %s, %s",
+		"prompts.stale":                  "используется предыдущая версия: %s",
+		"prompts.reload_failed":          "не удалось перечитать шаблоны: %v",
+		"prompts.render_failed":          "ошибка в шаблоне промпта %s: %v",
+		"help.usage":                     "показать расход токенов AI за сессию и за сегодня",
+		"usage.title":                    "Расход токенов AI:",
+		"usage.session":                  "эта сессия",
+		"usage.today":                    "%s сегодня",
+		"usage.line":                     "  %s: запросов %d, токенов %d (запрос %d, ответ %d)",
+		"usage.cost":                     ", ≈ %.4f %s",
+		"usage.limit":                    ", дневной лимит %d, осталось %d",
+		"usage.users":                    "Сегодня по пользователям:",
+		"usage.user_tokens":              "токенов %d, запросов %d",
+		"usage.quota_exceeded":           "дневной лимит токенов AI для %s исчерпан (%d из %d)",
+		"usage.login_required":           "запросы к AI ограничены дневным лимитом: сначала войдите (войти как [имя])",
+		"help.cache":                     "показать статистику кэша ответов AI или очистить его",
+		"args.cache":                     "[статистика | очистить]",
+		"help.nocache":                   "выполнить запрос, не используя кэш ответов AI",
+		"cache.usage":                    "использование: кэш [статистика | очистить]",
+		"cache.disabled":                 "кэш ответов AI отключён",
+		"cache.stats":                    "Кэш ответов AI: записей %d, %d байт, попаданий %d, промахов %d, вытеснено %d",
+		"cache.cleared":                  "Кэш ответов AI очищен",
+		"cache.clear_failed":             "не удалось очистить кэш: %v",
+		"cache.cli_only":                 "управление кэшем ответов AI доступно только в терминале",
+		"help.summarize":                 "пересказать длинный текстовый или HTML-файл по частям",
+		"args.file":                      "[файл]",
+		"summarize.task":                 "Кратко перескажи содержание",
+		"summarize.cli_only":             "пересказ файлов доступен только в терминале, в браузере можно пересказать страницу по ссылке",
+		"summarize.result":               "Краткое содержание %s:\n\n%s",
+		"summarize.part":                 "Часть %d:\n%s",
+		"summarize.truncated":            "(учтены первые %d из %d частей, остальное не поместилось в лимит)",
+		"ui.progress":                    "Пересказано частей: %d из %d",
+		"ui.progress_reduce":             "Собираю итоговый ответ…",
+	},
+	LocaleEn: {
+		"call.login_usage":               "invalid command format. Use: login as [name]",
+		"call.logged_in":                 "Logged in as %s. You can make calls now!",
+		"call.login_required":            "log in first with 'login as [name]'",
+		"call.usage":                     "invalid command format. Use: call [name]",
+		"call.save_failed":               "failed to save call data: %v",
+		"call.target_login_failed":       "login failed for %s: %v",
+		"call.cancelled":                 "call cancelled: %v",
+		"call.second_browser_failed":     "failed to open the second browser: %v",
+		"call.started":                   "Starting call: %s is calling %s",
+		"call.server_unavailable":        "cannot connect to the call server: %v",
+		"call.login_failed":              "login failed: %s",
+		"request.create_failed":          "failed to create request: %v",
+		"json.create_failed":             "failed to build JSON: %v",
+		"os.unsupported":                 "unsupported operating system",
+		"browser.open_failed":            "failed to open the browser: %v",
+		"browser.url_required":           "Please specify a URL or site name to open",
+		"browser.opening":                "Opening in browser: %s",
+		"media.file_required":            "Please specify a video or audio file name",
+		"media.open_failed":              "failed to open the media player: %v",
+		"media.opening":                  "Opening in media player: %s",
+		"file.not_found":                 "file '%s' not found in safe directories",
+		"file.search_failed":             "file not found in safe directories: %v",
+		"file.search_cancelled":          "file search cancelled: %v",
+		"file.name_required":             "Please specify a file name",
+		"file.open_failed":               "failed to open the file: %v",
+		"file.opening":                   "Opening file: %s",
+		"classify.calculation_failed":    "could not evaluate the expression",
+		"classify.unknown_type":          "unknown request type",
+		"classify.request_failed":        "classification request failed: %v",
+		"classify.server_error":          "classification server error: %s, response body: %s",
+		"classify.parse_response_failed": "failed to parse classification response: %v",
+		"classify.empty_response":        "empty response from the classifier",
+		"classify.json_not_found":        "no JSON found in the classifier response",
+		"classify.parse_failed":          "failed to parse classification: %v",
+		"website.url_missing":            "no URL in the classification",
+		"website.fetch_failed":           "failed to fetch the website: %v",
+		"website.empty_page":             "no text to analyze was found on %s",
+		"website.request_failed":         "analysis request failed: %v",
+		"website.server_error":           "analysis server error: %s, response body: %s",
+		"website.parse_failed":           "failed to parse analysis response: %v",
+		"website.analysis":               "Analysis of %s:\n\n%s",
+		"website.analysis_failed":        "Could not analyze the website content",
+		"llm.request_failed":             "AI request failed: %v",
+		"llm.server_error":               "server error: %s, response body: %s",
+		"llm.parse_failed":               "failed to parse JSON response: %v",
+		"llm.api_error":                  "API error: %s",
+		"llm.unavailable":                "the AI is temporarily unavailable, try again in %d s",
+		"llm.no_answer":                  "Could not get an answer from the AI",
+		"curl.saved":                     "CURL result saved to variable '%s'",
+		"curl.url_missing":               "curl: no URL specified",
+		"curl.request_failed":            "curl: failed to create request: %v",
+		"curl.do_failed":                 "curl: request failed: %v",
+		"curl.read_failed":               "curl: failed to read response: %v",
+		"curl.scheme_blocked":            "curl: the %s scheme is not supported, use http or https",
+		"curl.host_blocked":              "curl: requests to %s are forbidden",
+		"curl.host_not_allowed":          "curl: %s is not in the list of allowed hosts",
+		"curl.address_blocked":           "curl: %s belongs to an internal network, the request is forbidden",
+		"curl.content_type_blocked":      "curl: content type %s is not allowed",
+		"curl.too_large":                 "curl: the response is larger than %d bytes",
+		"curl.bad_syntax":                "curl: invalid command line: %v",
+		"curl.unknown_option":            "curl: unknown option %s",
+		"curl.missing_value":             "curl: option %s requires a value",
+		"curl.extra_argument":            "curl: unexpected argument '%s' (URL already given)",
+		"curl.bad_header":                "curl: invalid header '%s', expected 'Name: value'",
+		"curl.bad_max_time":              "curl: invalid --max-time value '%s'",
+		"curl.data_file_unsupported":     "curl: reading data from a file (%s @file) is not supported",
+		"curl.too_many_redirects":        "too many redirects (more than %d)",
+		"curl.output_not_allowed":        "curl: writing to %s is not allowed, use a safe directory",
+		"curl.write_failed":              "curl: failed to write file: %v",
+		"curl.output_exists":             "curl: file %s already exists, overwriting is not allowed",
+		"curl.output_remote":             "curl: saving to a file (-o) is only available in the terminal",
+		"curl.output_saved":              "%d bytes saved to %s",
+		"calc.cancelled":                 "evaluation cancelled: %v",
+		"calc.string_variables":          "error: the expression contains string variables, arithmetic is not allowed",
+		"calc.unbalanced_parens":         "unbalanced parentheses",
+		"calc.function_argument":         "the argument of %s must be a number",
+		"calc.function_domain":           "%s is not defined for %s",
+		"calc.string_arithmetic":         "strings cannot be used in arithmetic",
+		"calc.reserved_name":             "the name '%s' is reserved for the last result",
+		"calc.bad_path":                  "cannot read %s: %v",
+		"calc.jsonpath_syntax":           "jsonpath: %v",
+		"calc.jsonpath_usage":            "usage: jsonpath(variable, \"$.path.to[0].field\")",
+		"calc.jsonpath_source":           "jsonpath: variable '%s' not found",
+		"calc.jsonpath_not_json":         "jsonpath: the response in '%s' is not JSON",
+		"calc.string_comparison":         "comparisons with string variables are not allowed",
+		"calc.division_by_zero":          "division by zero",
+		"calc.unknown_expression":        "unknown expression: %s",
+		"calc.unknown_comparison":        "unknown comparison operator: %s",
+		"safedir.abs_failed":             "failed to resolve absolute path: %v",
+		"safedir.missing":                "directory does not exist: %s",
+		"safedir.added":                  "Directory added to the safe list: %s",
+		"dispatch.no_route":              "could not determine how to handle the request",
+		"help.title":                     "Available commands:",
+		"help.help":                      "list available commands",
+		"help.history":                   "last 10 commands",
+		"help.call_login":                "log in to the call server",
+		"help.call":                      "call a user",
+		"help.curl":                      "make an HTTP request (-X, -H, -d, --json, -u, -L, -I, -i, -s, -o, --max-time); x = curl URL keeps the response, fields x.status, x.headers, x.body.a[0]",
+		"help.assignment":                "store a result in a variable",
+		"help.open_link":                 "open a link in the browser",
+		"help.website_analysis":          "analyze the content of a linked website",
+		"help.calculation":               "evaluate an arithmetic expression or comparison",
+		"help.open":                      "open a file, media player or website",
+		"help.llm":                       "ask the AI a question",
+		"help.locale":                    "switch language (ru, en)",
+		"args.name":                      "[name]",
+		"args.url":                       "[options] [url]",
+		"args.assignment":                "[name] = [expression]",
+		"args.locale":                    "[ru|en]",
+		"locale.changed":                 "Language switched to: %s",
+		"locale.current":                 "Current language: %s",
+		"locale.unsupported":             "unsupported language: %s. Available: %s",
+		"ui.result":                      "Result",
+		"ui.error":                       "Error",
+		"ui.warning":                     "Warning",
+		"ui.ai_answer":                   "AI answer",
+		"ui.goodbye":                     "Goodbye!",
+		"ui.history_title":               "Last 10 commands:",
+		"ui.input_error":                 "Input error",
+		"help.explain":                   "show which route handles the input and how it is evaluated",
+		"args.input":                     "[input]",
+		"explain.input":                  "Input",
+		"explain.route":                  "Route",
+		"explain.reason":                 "Reason",
+		"explain.checked":                "Checked routes",
+		"explain.steps":                  "Evaluation steps",
+		"explain.fallbacks":              "If the route cannot handle it",
+		"explain.alias":                  "starts with the keyword «%s»",
+		"explain.assignment":             "the left side of «=» is a valid variable name «%s»",
+		"explain.calc.curl":              "the input starts with curl",
+		"explain.calc.link":              "the input contains a link",
+		"explain.calc.variable":          "the input is a variable name or result reference",
+		"explain.calc.url_or_path":       "has operators but looks like a URL or file path",
+		"explain.calc.operands":          "has arithmetic or comparison operators and numeric operands",
+		"explain.calc.no_operands":       "has operators but no numbers or numeric variables",
+		"explain.calc.number":            "the input is a number",
+		"explain.calc.parens":            "the input contains parentheses",
+		"explain.calc.no_math":           "no operators, numbers or parentheses",
+		"help.config":                    "show the current configuration (secrets redacted)",
+		"args.config":                    "[show]",
+		"config.usage":                   "usage: config [show]",
+		"config.source":                  "Configuration (source: %s):",
+		"config.defaults":                "defaults",
+		"config.cli_only":                "the configuration is only available in the terminal",
+		"help.reset":                     "clear the AI conversation memory",
+		"conversation.state":             "Calculator state (variables and recent results):",
+		"conversation.summary":           "Summary of the earlier conversation:",
+		"conversation.reset":             "AI conversation memory cleared",
+		"tools.error":                    "error: %v",
+		"tools.bad_arguments":            "invalid tool arguments: %v",
+		"tools.unknown":                  "unknown tool '%s'",
+		"tools.unknown_variable":         "variable '%s' not found",
+		"tools.bad_variable":             "invalid variable name '%s'",
+		"tools.no_variables":             "no variables or results yet",
+		"help.nl_math":                   "translate a natural-language problem into an expression and evaluate it",
+		"explain.nl_math":                "the local classifier considers it a calculation (confidence %.2f)",
+		"nlmath.no_expression":           "the AI could not produce an expression",
+		"nlmath.not_expression":          "«%s» is not a calculator expression",
+		"nlmath.eval_failed":             "expression «%s» cannot be evaluated: %v",
+		"nlmath.unverified":              "the AI answer was not verified by the calculator: %v",
+		"help.prompts":                   "show the system prompt templates or reload them from disk",
+		"args.prompts":                   "[reload]",
+		"prompts.usage":                  "usage: prompts [reload]",
+		"prompts.title":                  "Prompt templates (%s):",
+		"prompts.embedded":               "embedded",
+		"prompts.line":                   "  %s — version %s, %s",
+		"prompts.stale":                  "using the previous version: %s",
+		"prompts.reload_failed":          "failed to reload templates: %v",
+		"prompts.render_failed":          "error in prompt template %s: %v",
+		"help.usage":                     "show AI token usage for this session and today",
+		"usage.title":                    "AI token usage:",
+		"usage.session":                  "this session",
+		"usage.today":                    "%s today",
+		"usage.line":                     "  %s: %d requests, %d tokens (prompt %d, completion %d)",
+		"usage.cost":                     ", ≈ %.4f %s",
+		"usage.limit":                    ", daily limit %d, %d left",
+		"usage.users":                    "Today by user:",
+		"usage.user_tokens":              "%d tokens, %d requests",
+		"usage.quota_exceeded":           "daily AI token limit for %s is exhausted (%d of %d)",
+		"usage.login_required":           "AI requests are limited by a daily quota: log in first (login as [name])",
+		"help.cache":                     "show AI response cache statistics or clear it",
+		"args.cache":                     "[stats | clear]",
+		"help.nocache":                   "run a request without the AI response cache",
+		"cache.usage":                    "usage: cache [stats | clear]",
+		"cache.disabled":                 "the AI response cache is disabled",
+		"cache.stats":                    "AI response cache: %d entries, %d bytes, %d hits, %d misses, %d evicted",
+		"cache.cleared":                  "AI response cache cleared",
+		"cache.clear_failed":             "failed to clear the cache: %v",
+		"cache.cli_only":                 "the AI response cache can only be managed from the terminal",
+		"help.summarize":                 "summarize a long text or HTML file chunk by chunk",
+		"args.file":                      "[file]",
+		"summarize.task":                 "Briefly summarize the content",
+		"summarize.cli_only":             "files can only be summarized from the terminal, in the browser summarize a page by its link",
+		"summarize.result":               "Summary of %s:\n\n%s",
+		"summarize.part":                 "Part %d:\n%s",
+		"summarize.truncated":            "(the first %d of %d parts were used, the rest exceeded the limit)",
+		"ui.progress":                    "Parts summarized: %d of %d",
+		"ui.progress_reduce":             "Composing the final answer…",
+	},
+}
+
+var keywords = map[Locale]map[string][]string{
+	LocaleRu: {
+		"help":        {"помощь", "справка"},
+		"history":     {"история"},
+		"locale":      {"язык"},
+		"explain":     {"разбор"},
+		"config":      {"конфиг"},
+		"config_show": {"показать"},
+		"reset":       {"сброс"},
+		"cache":       {"кэш"},
+		"cache_stats": {"статистика"},
+		"cache_clear": {"очистить"},
+		"nocache":     {"без-кэша"},
+		"usage":       {"расход"},
+		"prompts":     {"промпты"},
+		"reload":      {"перезагрузить"},
+		"summarize":   {"перескажи", "резюмируй"},
+		"call_login":  {"войти как"},
+		"call":        {"позвонить"},
+		"open":        {"открой"},
+		"simple_open": {"открой файл", "включи видео", "включи музыку", "открой документ"},
+		"analysis":    {"расскажи", "проанализируй", "дай сводку", "анализ", "что на"},
+		"browser":     {"открой сайт", "открой в браузере", "зайди на", "посети"},
+		"media":       {"видео", "музыка", "фильм", "проигрыватель", "включи"},
+		"file":        {"файл", "открой", "документ", "текст"},
+	},
+	LocaleEn: {
+		"help":        {"help"},
+		"history":     {"history"},
+		"locale":      {"language"},
+		"explain":     {"explain"},
+		"config":      {"config"},
+		"config_show": {"show"},
+		"reset":       {"reset"},
+		"cache":       {"cache"},
+		"cache_stats": {"stats"},
+		"cache_clear": {"clear"},
+		"nocache":     {"nocache"},
+		"usage":       {"usage"},
+		"prompts":     {"prompts"},
+		"reload":      {"reload"},
+		"summarize":   {"summarize"},
+		"call_login":  {"login as"},
+		"call":        {"call"},
+		"open":        {"open"},
+		"simple_open": {"open file", "play video", "play music", "open document"},
+		"analysis":    {"summary", "analyze"},
+		"browser":     {"open website", "open in browser", "go to", "visit"},
+		"media":       {"video", "music", "movie", "player", "play"},
+		"file":        {"file", "open", "document", "text"},
+	},
+}
+
+func SupportedLocales() []Locale {
+	locales := make([]Locale, 0, len(messages))
+	for locale := range messages {
+		locales = append(locales, locale)
+	}
+	sort.Slice(locales, func(a, b int) bool { return locales[a] < locales[b] })
+	return locales
+}
+
+func ParseLocale(value string) (Locale, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if idx := strings.IndexAny(value, "-_"); idx != -1 {
+		value = value[:idx]
+	}
+	locale := Locale(value)
+	_, ok := messages[locale]
+	return locale, ok
+}
+
+func ParseAcceptLanguage(header string) Locale {
+	bestLocale := DefaultLocale
+	bestWeight := -1.0
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale, ok := ParseLocale(fields[0])
+		if !ok {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				fmt.Sscanf(param[2:], "%g", &weight)
+			}
+		}
+		if weight > bestWeight {
+			bestLocale, bestWeight = locale, weight
+		}
+	}
+	return bestLocale
+}
+
+func Message(locale Locale, key string) string {
+	if msg, ok := messages[locale][key]; ok {
+		return msg
+	}
+	if msg, ok := messages[DefaultLocale][key]; ok {
+		return msg
+	}
+	return key
+}
+
+func keywordsFor(group string) []string {
+	var result []string
+	for _, locale := range SupportedLocales() {
+		result = append(result, keywords[locale][group]...)
+	}
+	return result
+}
+
+func (i *Interpreter) localeKeywords(group string) []string {
+	return keywords[i.Locale()][group]
+}
+
+func (i *Interpreter) containsKeyword(lowerInput string, group string) bool {
+	for _, keyword := range i.localeKeywords(group) {
+		if containsWord(lowerInput, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interpreter) hasKeywordPrefix(lowerInput string, group string) bool {
+	for _, keyword := range i.localeKeywords(group) {
+		if strings.HasPrefix(lowerInput, keyword+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWord(text string, word string) bool {
+	for offset := 0; offset < len(text); {
+		idx := strings.Index(text[offset:], word)
+		if idx < 0 {
+			return false
+		}
+		start := offset + idx
+		end := start + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (i *Interpreter) Locale() Locale {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.locale
+}
+
+func (i *Interpreter) SetLocale(locale Locale) {
+	i.mu.Lock()
+	i.locale = locale
+	i.mu.Unlock()
+}
+
+func (i *Interpreter) msg(key string) string {
+	return Message(i.Locale(), key)
+}
+
+func (i *Interpreter) sprintf(key string, args ...interface{}) string {
+	return fmt.Sprintf(i.msg(key), args...)
+}
+
+func (i *Interpreter) errorf(key string, args ...interface{}) error {
+	return fmt.Errorf(i.msg(key), args...)
+}
+
+func (i *Interpreter) handleLocaleCommand(input string) (string, error) {
+	parts := strings.Fields(input)
+	if len(parts) < 2 {
+		return i.sprintf("locale.current", i.Locale()), nil
+	}
+
+	locale, ok := ParseLocale(parts[len(parts)-1])
+	if !ok {
+		var available []string
+		for _, supported := range SupportedLocales() {
+			available = append(available, string(supported))
+		}
+		return "", i.errorf("locale.unsupported", parts[len(parts)-1], strings.Join(available, ", "))
+	}
+
+	i.SetLocale(locale)
+	return i.sprintf("locale.changed", locale), nil
+}
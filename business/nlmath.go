@@ -0,0 +1,73 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+type VerifiedExpression struct {
+	Expression string
+	Value      interface{}
+}
+
+func (i *Interpreter) isNaturalLanguageMath(input string) bool {
+	classification, confident := i.classifyLocally(input)
+	return confident && classification.Type == "calculation"
+}
+
+func (i *Interpreter) handleNaturalLanguageMath(ctx context.Context, input string) (interface{}, bool, error) {
+	expression, err := i.translateToExpression(ctx, input)
+	if err != nil {
+		return nil, false, i.errorf("nlmath.unverified", err)
+	}
+
+	if calculable, _ := i.checkCalculable(expression); !calculable {
+		return nil, false, i.errorf("nlmath.unverified", i.errorf("nlmath.not_expression", expression))
+	}
+
+	value, err := i.evaluateExpression(ctx, expression)
+	if err != nil {
+		return nil, false, i.errorf("nlmath.unverified", i.errorf("nlmath.eval_failed", expression, err))
+	}
+	return VerifiedExpression{Expression: expression, Value: value}, true, nil
+}
+
+func (i *Interpreter) translateToExpression(ctx context.Context, input string) (string, error) {
+	systemPrompt, err := i.renderPrompt(PromptTranslate, PromptData{})
+	if err != nil {
+		return "", err
+	}
+
+	response, err := i.chat(ctx, ChatRequest{
+		Purpose: LLMPurposeTranslate,
+		Messages: []ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: input},
+		},
+		MaxTokens: 200,
+	}, llmErrorKeys{request: "llm.request_failed", status: "llm.server_error", parse: "llm.parse_failed"})
+	if err != nil {
+		return "", err
+	}
+
+	expression := parseExpressionResponse(response.Content)
+	if expression == "" {
+		return "", i.errorf("nlmath.no_expression")
+	}
+	return expression, nil
+}
+
+func parseExpressionResponse(content string) string {
+	jsonStart := strings.Index(content, "{")
+	jsonEnd := strings.LastIndex(content, "}") + 1
+	if jsonStart != -1 && jsonEnd > jsonStart {
+		var parsed struct {
+			Expression string `json:"expression"`
+		}
+		if err := json.Unmarshal([]byte(content[jsonStart:jsonEnd]), &parsed); err == nil {
+			return strings.TrimSpace(parsed.Expression)
+		}
+	}
+	return strings.Trim(firstLine(content), " `")
+}
@@ -0,0 +1,182 @@
+package business
+
+import (
+	"calculator/config"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var reservedNetworks = mustParseNetworks(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+)
+
+type outboundError struct {
+	err error
+}
+
+func (e *outboundError) Error() string {
+	return e.err.Error()
+}
+
+type outboundPolicy struct {
+	cfg           config.Outbound
+	allowNetworks []*net.IPNet
+}
+
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func (i *Interpreter) outboundPolicy() *outboundPolicy {
+	policy := &outboundPolicy{cfg: i.Config().Outbound}
+	for _, cidr := range policy.cfg.AllowNetworks {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			policy.allowNetworks = append(policy.allowNetworks, network)
+		}
+	}
+	return policy
+}
+
+func (i *Interpreter) outboundClient(policy *outboundPolicy, location bool) (*http.Client, *http.Transport) {
+	i.mu.RLock()
+	client := *i.httpClient
+	i.mu.RUnlock()
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			return i.checkOutboundAddress(policy, address)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	client.Transport = transport
+
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !location {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= curlMaxRedirects {
+			return i.errorf("curl.too_many_redirects", curlMaxRedirects)
+		}
+		return i.checkOutboundURL(policy, req.URL)
+	}
+	return &client, transport
+}
+
+func (i *Interpreter) checkOutboundURL(policy *outboundPolicy, target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return &outboundError{i.errorf("curl.scheme_blocked", target.Scheme)}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(target.Hostname()), ".")
+	if matchesHost(host, policy.cfg.DenyHosts) {
+		return &outboundError{i.errorf("curl.host_blocked", host)}
+	}
+	if len(policy.cfg.AllowHosts) > 0 && !matchesHost(host, policy.cfg.AllowHosts) {
+		return &outboundError{i.errorf("curl.host_not_allowed", host)}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return i.checkOutboundIP(policy, ip)
+	}
+	return nil
+}
+
+func (i *Interpreter) checkOutboundAddress(policy *outboundPolicy, address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &outboundError{i.errorf("curl.address_blocked", host)}
+	}
+	return i.checkOutboundIP(policy, ip)
+}
+
+func (i *Interpreter) checkOutboundIP(policy *outboundPolicy, ip net.IP) error {
+	if policy.cfg.AllowPrivate || !isInternalIP(ip) {
+		return nil
+	}
+	for _, network := range policy.allowNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	return &outboundError{i.errorf("curl.address_blocked", ip)}
+}
+
+func isInternalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesHost(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(pattern)), "*.")
+		if host == pattern || strings.HasSuffix(host, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interpreter) checkContentType(policy *outboundPolicy, contentType string) error {
+	if len(policy.cfg.ContentTypes) == 0 || contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	for _, allowed := range policy.cfg.ContentTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		switch {
+		case strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed):
+			return nil
+		case strings.HasPrefix(allowed, "+") && strings.HasSuffix(mediaType, allowed):
+			return nil
+		case mediaType == allowed:
+			return nil
+		}
+	}
+	return i.errorf("curl.content_type_blocked", mediaType)
+}
+
+func policyViolation(err error) error {
+	var blocked *outboundError
+	if errors.As(err, &blocked) {
+		return blocked.err
+	}
+	return nil
+}
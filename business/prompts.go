@@ -0,0 +1,277 @@
+package business
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	PromptClassify       = "classify"
+	PromptAnalyze        = "analyze"
+	PromptAnalyzeRequest = "analyze_request"
+	PromptAnswer         = "answer"
+	PromptTranslate      = "translate"
+	PromptSummarize      = "summarize"
+	PromptSummarizeChunk = "summarize_chunk"
+
+	promptExt = ".tmpl"
+)
+
+//go:embed prompts/*.tmpl
+var defaultPromptFiles embed.FS
+
+var promptVersionPattern = regexp.MustCompile(`\{\{/\*\s*version:\s*([^\s*]+)`)
+
+var promptFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+type PromptData struct {
+	Locale    Locale
+	User      string
+	State     string
+	Variables []string
+	Request   string
+	URL       string
+	Content   string
+	Task      string
+	Title     string
+	Headings  []string
+	Links     []PageLink
+	Part      int
+	Parts     int
+}
+
+type promptTemplate struct {
+	tmpl    *template.Template
+	version string
+	source  string
+	modTime time.Time
+	err     error
+}
+
+type PromptInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	Error   string `json:"error,omitempty"`
+}
+
+type PromptLibrary struct {
+	mu        sync.Mutex
+	dir       string
+	templates map[string]*promptTemplate
+}
+
+var defaultPrompts = mustPromptLibrary("")
+
+func LoadPromptLibrary(dir string) (*PromptLibrary, error) {
+	library := &PromptLibrary{dir: dir}
+	if err := library.Reload(); err != nil {
+		return nil, err
+	}
+	return library, nil
+}
+
+func mustPromptLibrary(dir string) *PromptLibrary {
+	library, err := LoadPromptLibrary(dir)
+	if err != nil {
+		panic(err)
+	}
+	return library
+}
+
+func (l *PromptLibrary) Reload() error {
+	templates := make(map[string]*promptTemplate)
+
+	embedded, err := defaultPromptFiles.ReadDir("prompts")
+	if err != nil {
+		return err
+	}
+	for _, entry := range embedded {
+		name := strings.TrimSuffix(entry.Name(), promptExt)
+		data, err := defaultPromptFiles.ReadFile("prompts/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		prompt, err := parsePrompt(name, string(data))
+		if err != nil {
+			return err
+		}
+		prompt.source = "embedded"
+		templates[name] = prompt
+	}
+
+	if l.dir != "" {
+		files, err := filepath.Glob(filepath.Join(l.dir, "*"+promptExt))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("в директории %s нет шаблонов *%s", l.dir, promptExt)
+		}
+		for _, path := range files {
+			name := strings.TrimSuffix(filepath.Base(path), promptExt)
+			prompt, err := loadPromptFile(name, path)
+			if err != nil {
+				return err
+			}
+			templates[name] = prompt
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.templates = templates
+	return nil
+}
+
+func loadPromptFile(name, path string) (*promptTemplate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := parsePrompt(name, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("шаблон %s: %v", path, err)
+	}
+	prompt.source = path
+	prompt.modTime = info.ModTime()
+	return prompt, nil
+}
+
+func parsePrompt(name, text string) (*promptTemplate, error) {
+	tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	version := "-"
+	if match := promptVersionPattern.FindStringSubmatch(text); match != nil {
+		version = match[1]
+	}
+	return &promptTemplate{tmpl: tmpl, version: version}, nil
+}
+
+func (l *PromptLibrary) Render(name string, data PromptData) (string, error) {
+	l.mu.Lock()
+	prompt, ok := l.templates[name]
+	if ok && prompt.source != "embedded" {
+		prompt = l.refresh(name, prompt)
+	}
+	l.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("шаблон '%s' не найден", name)
+	}
+
+	var buf bytes.Buffer
+	if err := prompt.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func (l *PromptLibrary) refresh(name string, prompt *promptTemplate) *promptTemplate {
+	info, err := os.Stat(prompt.source)
+	if err != nil || info.ModTime().Equal(prompt.modTime) {
+		return prompt
+	}
+
+	updated, err := loadPromptFile(name, prompt.source)
+	if err != nil {
+		prompt.err = err
+		prompt.modTime = info.ModTime()
+		return prompt
+	}
+	l.templates[name] = updated
+	return updated
+}
+
+func (l *PromptLibrary) Dir() string {
+	return l.dir
+}
+
+func (l *PromptLibrary) Info() []PromptInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	infos := make([]PromptInfo, 0, len(l.templates))
+	for name, prompt := range l.templates {
+		if prompt.source != "embedded" {
+			prompt = l.refresh(name, prompt)
+		}
+		info := PromptInfo{Name: name, Version: prompt.version, Source: prompt.source}
+		if prompt.err != nil {
+			info.Error = prompt.err.Error()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(a, b int) bool { return infos[a].Name < infos[b].Name })
+	return infos
+}
+
+func (i *Interpreter) SetPrompts(prompts *PromptLibrary) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.prompts = prompts
+}
+
+func (i *Interpreter) Prompts() *PromptLibrary {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.prompts
+}
+
+func (i *Interpreter) renderPrompt(name string, data PromptData) (string, error) {
+	data.Locale = i.Locale()
+	data.User = i.usageUser()
+	data.State = strings.Join(i.stateLines(), "\n")
+	data.Variables = i.VariableNames()
+
+	prompt, err := i.Prompts().Render(name, data)
+	if err != nil {
+		return "", i.errorf("prompts.render_failed", name, err)
+	}
+	return prompt, nil
+}
+
+func (i *Interpreter) handlePromptsCommand(input string) (string, error) {
+	prompts := i.Prompts()
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) > 1 {
+		if !isKeyword(fields[1], "reload") {
+			return "", i.errorf("prompts.usage")
+		}
+		if err := prompts.Reload(); err != nil {
+			return "", i.errorf("prompts.reload_failed", err)
+		}
+	}
+
+	source := prompts.Dir()
+	if source == "" {
+		source = i.msg("prompts.embedded")
+	}
+	lines := []string{i.sprintf("prompts.title", source)}
+	for _, info := range prompts.Info() {
+		line := i.sprintf("prompts.line", info.Name, info.Version, info.Source)
+		if info.Error != "" {
+			line += " — " + i.sprintf("prompts.stale", info.Error)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,88 @@
+package business
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ResultKind string
+
+const (
+	ResultNumber    ResultKind = "number"
+	ResultBool      ResultKind = "bool"
+	ResultText      ResultKind = "text"
+	ResultList      ResultKind = "list"
+	ResultAction    ResultKind = "action"
+	ResultLLMAnswer ResultKind = "llm-answer"
+)
+
+const (
+	RouteHelp            = "help"
+	RouteHistory         = "history"
+	RouteLocale          = "locale"
+	RouteExplain         = "explain"
+	RouteConfig          = "config"
+	RouteReset           = "reset"
+	RouteCache           = "cache"
+	RouteNoCache         = "nocache"
+	RouteUsage           = "usage"
+	RoutePrompts         = "prompts"
+	RouteCallLogin       = "call-login"
+	RouteCall            = "call"
+	RouteCurl            = "curl"
+	RouteAssignment      = "assignment"
+	RouteOpen            = "open"
+	RouteOpenLink        = "open-link"
+	RouteWebsiteAnalysis = "website-analysis"
+	RouteSummarize       = "summarize"
+	RouteCalculation     = "calculation"
+	RouteNLMath          = "nl-math"
+	RouteLLM             = "llm"
+)
+
+type Result struct {
+	Kind       ResultKind    `json:"kind"`
+	Value      interface{}   `json:"value"`
+	Text       string        `json:"text"`
+	Route      string        `json:"route"`
+	Ref        string        `json:"ref,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+	Duration   time.Duration `json:"-"`
+	Warnings   []string      `json:"warnings,omitempty"`
+	volatile   bool
+}
+
+func newResult(route string, kind ResultKind, value interface{}) *Result {
+	expression := ""
+	if verified, ok := value.(VerifiedExpression); ok {
+		expression, value = verified.Expression, verified.Value
+	}
+	if kind == "" {
+		kind = kindOf(value)
+	}
+	return &Result{Kind: kind, Value: value, Route: route, Expression: expression}
+}
+
+func kindOf(value interface{}) ResultKind {
+	switch value.(type) {
+	case float64:
+		return ResultNumber
+	case bool:
+		return ResultBool
+	case []string:
+		return ResultList
+	default:
+		return ResultText
+	}
+}
+
+func (r *Result) MarshalJSON() ([]byte, error) {
+	type plainResult Result
+	return json.Marshal(struct {
+		*plainResult
+		DurationMs float64 `json:"durationMs"`
+	}{
+		plainResult: (*plainResult)(r),
+		DurationMs:  float64(r.Duration.Microseconds()) / 1000,
+	})
+}
@@ -0,0 +1,223 @@
+package business
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	breakerWindow     = 20
+	defaultRetryDelay = 500 * time.Millisecond
+	defaultMaxDelay   = 10 * time.Second
+)
+
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	base, limit := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = defaultRetryDelay
+	}
+	if limit <= 0 {
+		limit = defaultMaxDelay
+	}
+
+	delay := base << uint(attempt)
+	if delay <= 0 || delay > limit {
+		delay = limit
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (p RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return defaultMaxDelay
+	}
+	return p.MaxDelay
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	threshold   float64
+	minRequests int
+	cooldown    time.Duration
+	state       breakerState
+	outcomes    []bool
+	openedAt    time.Time
+	probing     bool
+	now         func() time.Time
+}
+
+func NewCircuitBreaker(threshold float64, minRequests int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if minRequests <= 0 {
+		minRequests = 1
+	}
+	return &CircuitBreaker{threshold: threshold, minRequests: minRequests, cooldown: cooldown, now: time.Now}
+}
+
+func (b *CircuitBreaker) Allow() (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		wait := b.cooldown - b.now().Sub(b.openedAt)
+		if wait > 0 {
+			return false, wait
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true, 0
+	case breakerHalfOpen:
+		if b.probing {
+			return false, 0
+		}
+		b.probing = true
+		return true, 0
+	default:
+		return true, 0
+	}
+}
+
+func (b *CircuitBreaker) Record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if success {
+			b.state = breakerClosed
+			b.outcomes = nil
+		} else {
+			b.trip()
+		}
+		return
+	}
+	if b.state == breakerOpen {
+		return
+	}
+
+	b.outcomes = append(b.outcomes, success)
+	if len(b.outcomes) > breakerWindow {
+		b.outcomes = b.outcomes[len(b.outcomes)-breakerWindow:]
+	}
+	if len(b.outcomes) < b.minRequests {
+		return
+	}
+	failures := 0
+	for _, ok := range b.outcomes {
+		if !ok {
+			failures++
+		}
+	}
+	if float64(failures)/float64(len(b.outcomes)) >= b.threshold {
+		b.trip()
+	}
+}
+
+func (b *CircuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *CircuitBreaker) trip() {
+	b.state = breakerOpen
+	b.openedAt = b.now()
+	b.outcomes = nil
+}
+
+type breakerBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	breaker *CircuitBreaker
+	failed  bool
+	once    sync.Once
+}
+
+func (b *breakerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.ctx.Err() == nil {
+		b.failed = true
+	}
+	return n, err
+}
+
+func (b *breakerBody) Close() error {
+	b.once.Do(func() {
+		if !b.failed && b.ctx.Err() != nil {
+			b.breaker.Release()
+			return
+		}
+		b.breaker.Record(!b.failed)
+	})
+	return b.ReadCloser.Close()
+}
+
+type llmClient struct {
+	http    *http.Client
+	retry   RetryPolicy
+	breaker *CircuitBreaker
+}
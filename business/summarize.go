@@ -0,0 +1,237 @@
+package business
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	ProgressMap    = "map"
+	ProgressReduce = "reduce"
+)
+
+type Progress struct {
+	Stage string `json:"stage"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+type progressKey struct{}
+
+func WithProgress(ctx context.Context, onProgress func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, onProgress)
+}
+
+func reportProgress(ctx context.Context, progress Progress) {
+	if onProgress, _ := ctx.Value(progressKey{}).(func(Progress)); onProgress != nil {
+		onProgress(progress)
+	}
+}
+
+func (i *Interpreter) analyzePage(ctx context.Context, source string, page *PageContent, request, task string) (string, error) {
+	cfg := i.Config().Website
+	chunks := chunkText(page.Text, cfg.ChunkSize)
+	if len(chunks) == 0 && page.Title == "" {
+		return "", i.errorf("website.empty_page", source)
+	}
+
+	skipped := 0
+	if len(chunks) > cfg.MaxChunks {
+		skipped = len(chunks) - cfg.MaxChunks
+		chunks = chunks[:cfg.MaxChunks]
+	}
+
+	var content string
+	switch len(chunks) {
+	case 0:
+	case 1:
+		content = chunks[0]
+	default:
+		summaries, err := i.summarizeChunks(ctx, source, chunks, task, cfg.Workers)
+		if err != nil {
+			return "", err
+		}
+		parts := make([]string, len(summaries))
+		for idx, summary := range summaries {
+			parts[idx] = i.sprintf("summarize.part", idx+1, summary)
+		}
+		content = strings.Join(parts, "\n\n")
+		reportProgress(ctx, Progress{Stage: ProgressReduce, Done: len(chunks), Total: len(chunks)})
+	}
+
+	systemPrompt, err := i.renderPrompt(PromptAnalyze, PromptData{})
+	if err != nil {
+		return "", err
+	}
+	userPrompt, err := i.renderPrompt(PromptAnalyzeRequest, PromptData{
+		Request:  request,
+		URL:      source,
+		Content:  content,
+		Task:     task,
+		Title:    page.Title,
+		Headings: page.Headings,
+		Links:    page.Links,
+		Parts:    len(chunks),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	response, err := i.chat(ctx, ChatRequest{
+		Purpose: LLMPurposeAnalyze,
+		Messages: []ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		MaxTokens: 2048,
+	}, llmErrorKeys{request: "website.request_failed", status: "website.server_error", parse: "website.parse_failed"})
+	if err != nil {
+		return "", err
+	}
+	if response.Content == "" {
+		return "", nil
+	}
+
+	answer := response.Content
+	if skipped > 0 {
+		answer += "\n\n" + i.sprintf("summarize.truncated", len(chunks), len(chunks)+skipped)
+	}
+	return answer, nil
+}
+
+func (i *Interpreter) summarizeChunks(ctx context.Context, source string, chunks []string, task string, workers int) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	systemPrompt, err := i.renderPrompt(PromptSummarize, PromptData{})
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]string, len(chunks))
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		done     int
+		firstErr error
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	if workers > len(chunks) {
+		workers = len(chunks)
+	}
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				summary, err := i.summarizeChunk(ctx, systemPrompt, source, chunks[idx], task, idx+1, len(chunks))
+				if err != nil {
+					fail(err)
+					continue
+				}
+
+				mu.Lock()
+				summaries[idx] = summary
+				done++
+				reportProgress(ctx, Progress{Stage: ProgressMap, Done: done, Total: len(chunks)})
+				mu.Unlock()
+			}
+		}()
+	}
+
+	reportProgress(ctx, Progress{Stage: ProgressMap, Total: len(chunks)})
+	for idx := range chunks {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, i.errorf("website.request_failed", err)
+	}
+	return summaries, nil
+}
+
+func (i *Interpreter) summarizeChunk(ctx context.Context, systemPrompt, source, chunk, task string, part, parts int) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", i.errorf("website.request_failed", err)
+	}
+
+	userPrompt, err := i.renderPrompt(PromptSummarizeChunk, PromptData{
+		URL:     source,
+		Content: chunk,
+		Task:    task,
+		Part:    part,
+		Parts:   parts,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	response, err := i.chat(ctx, ChatRequest{
+		Purpose: LLMPurposeSummarize,
+		Messages: []ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		MaxTokens: 1024,
+	}, llmErrorKeys{request: "website.request_failed", status: "website.server_error", parse: "website.parse_failed"})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response.Content), nil
+}
+
+func (i *Interpreter) handleSummarizeCommand(ctx context.Context, input string) (interface{}, error) {
+	filename := trimAlias(input, keywordsFor("summarize"))
+	if filename == "" {
+		return nil, i.errorf("file.name_required")
+	}
+	if i.isRemote() {
+		return nil, i.errorf("summarize.cli_only")
+	}
+
+	path, err := i.findFileInSafeDirectories(ctx, filename)
+	if err != nil {
+		return nil, i.errorf("file.search_failed", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, i.errorf("file.open_failed", err)
+	}
+
+	page := &PageContent{Text: strings.TrimSpace(string(data))}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		page = ExtractPage(string(data), "")
+	}
+
+	name := filepath.Base(path)
+	answer, err := i.analyzePage(ctx, name, page, input, i.msg("summarize.task"))
+	if err != nil {
+		return nil, err
+	}
+	if answer == "" {
+		return i.msg("website.analysis_failed"), nil
+	}
+	return i.sprintf("summarize.result", name, answer), nil
+}
@@ -0,0 +1,143 @@
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	toolEvaluate      = "evaluate"
+	toolConvertUnits  = "convert_units"
+	toolGetVariable   = "get_variable"
+	toolSetVariable   = "set_variable"
+	toolListVariables = "list_variables"
+)
+
+var calculatorTools = []Tool{
+	newTool(toolEvaluate, "Вычислить арифметическое выражение или сравнение калькулятором. Поддерживаются + - * / скобки, сравнения и переменные (ans, _1, _2, ...).",
+		map[string]interface{}{
+			"expression": map[string]string{"type": "string", "description": "выражение, например (2+3)*4 или x*2"},
+		}, "expression"),
+	newTool(toolConvertUnits, "Перевести значение между единицами измерения: длина (mm, cm, m, km, in, ft, yd, mi), масса (mg, g, kg, t, oz, lb), время (ms, s, min, h, day, week), объём (ml, l, m3, gal), скорость (m/s, km/h, mph), данные (b, kb, mb, gb, tb), температура (c, f, k).",
+		map[string]interface{}{
+			"value": map[string]string{"type": "number", "description": "исходное значение"},
+			"from":  map[string]string{"type": "string", "description": "исходная единица"},
+			"to":    map[string]string{"type": "string", "description": "целевая единица"},
+		}, "value", "from", "to"),
+	newTool(toolGetVariable, "Получить значение переменной калькулятора или результата (ans, _1, _2, ...).",
+		map[string]interface{}{
+			"name": map[string]string{"type": "string", "description": "имя переменной"},
+		}, "name"),
+	newTool(toolSetVariable, "Вычислить выражение и сохранить результат в переменную калькулятора.",
+		map[string]interface{}{
+			"name":       map[string]string{"type": "string", "description": "имя переменной"},
+			"expression": map[string]string{"type": "string", "description": "выражение для вычисления"},
+		}, "name", "expression"),
+	newTool(toolListVariables, "Показать все переменные калькулятора и последние результаты.",
+		map[string]interface{}{}),
+}
+
+func newTool(name, description string, properties map[string]interface{}, required ...string) Tool {
+	parameters := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		parameters["required"] = required
+	}
+	return Tool{Type: "function", Function: ToolFunction{Name: name, Description: description, Parameters: parameters}}
+}
+
+func (i *Interpreter) chatWithTools(ctx context.Context, req ChatRequest, keys llmErrorKeys) (*ChatResponse, error) {
+	rounds := i.Config().LLM.MaxToolRounds
+	if rounds <= 0 {
+		return i.chat(ctx, req, keys)
+	}
+
+	req.Tools = calculatorTools
+	req.Messages = append([]ChatMessage(nil), req.Messages...)
+	for round := 0; round < rounds; round++ {
+		response, err := i.chat(ctx, req, keys)
+		if err != nil || len(response.ToolCalls) == 0 {
+			return response, err
+		}
+
+		req.Messages = append(req.Messages, ChatMessage{Role: "assistant", Content: response.Content, ToolCalls: response.ToolCalls})
+		for _, call := range response.ToolCalls {
+			req.Messages = append(req.Messages, ChatMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Name:       call.Function.Name,
+				Content:    i.runTool(ctx, call),
+			})
+		}
+	}
+
+	req.Tools = nil
+	return i.chat(ctx, req, keys)
+}
+
+func (i *Interpreter) runTool(ctx context.Context, call ToolCall) string {
+	result, err := i.executeTool(ctx, call)
+	if err != nil {
+		return i.sprintf("tools.error", err)
+	}
+	return result
+}
+
+func (i *Interpreter) executeTool(ctx context.Context, call ToolCall) (string, error) {
+	var args struct {
+		Expression string  `json:"expression"`
+		Name       string  `json:"name"`
+		Value      float64 `json:"value"`
+		From       string  `json:"from"`
+		To         string  `json:"to"`
+	}
+	if arguments := strings.TrimSpace(call.Function.Arguments); arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", i.errorf("tools.bad_arguments", err)
+		}
+	}
+
+	switch call.Function.Name {
+	case toolEvaluate:
+		value, err := i.Evaluate(ctx, args.Expression)
+		if err != nil {
+			return "", err
+		}
+		return i.formatValue(value), nil
+	case toolConvertUnits:
+		value, err := ConvertUnits(args.Value, args.From, args.To)
+		if err != nil {
+			return "", err
+		}
+		return i.valueToString(value), nil
+	case toolGetVariable:
+		value, ok := i.lookupVariable(args.Name)
+		if !ok {
+			return "", i.errorf("tools.unknown_variable", args.Name)
+		}
+		return i.formatValue(value), nil
+	case toolSetVariable:
+		if !isValidVariableName(args.Name) || isReservedName(args.Name) {
+			return "", i.errorf("tools.bad_variable", args.Name)
+		}
+		value, err := i.Evaluate(ctx, args.Expression)
+		if err != nil {
+			return "", err
+		}
+		i.mu.Lock()
+		i.variables[args.Name] = value
+		i.mu.Unlock()
+		return i.formatValue(value), nil
+	case toolListVariables:
+		lines := i.stateLines()
+		if len(lines) == 0 {
+			return i.msg("tools.no_variables"), nil
+		}
+		return strings.Join(lines, "\n"), nil
+	default:
+		return "", i.errorf("tools.unknown", call.Function.Name)
+	}
+}
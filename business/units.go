@@ -0,0 +1,105 @@
+package business
+
+import (
+	"fmt"
+	"strings"
+)
+
+type unit struct {
+	dimension string
+	factor    float64
+}
+
+var units = map[string]unit{
+	"mm": {"length", 0.001}, "мм": {"length", 0.001},
+	"cm": {"length", 0.01}, "см": {"length", 0.01},
+	"m": {"length", 1}, "м": {"length", 1},
+	"km": {"length", 1000}, "км": {"length", 1000},
+	"in": {"length", 0.0254}, "дюйм": {"length", 0.0254},
+	"ft": {"length", 0.3048}, "фут": {"length", 0.3048},
+	"yd": {"length", 0.9144}, "ярд": {"length", 0.9144},
+	"mi": {"length", 1609.344}, "миля": {"length", 1609.344},
+
+	"mg": {"mass", 0.000001}, "мг": {"mass", 0.000001},
+	"g": {"mass", 0.001}, "г": {"mass", 0.001},
+	"kg": {"mass", 1}, "кг": {"mass", 1},
+	"t": {"mass", 1000}, "т": {"mass", 1000},
+	"oz": {"mass", 0.028349523125}, "унция": {"mass", 0.028349523125},
+	"lb": {"mass", 0.45359237}, "фунт": {"mass", 0.45359237},
+
+	"ms": {"time", 0.001}, "мс": {"time", 0.001},
+	"s": {"time", 1}, "с": {"time", 1}, "сек": {"time", 1},
+	"min": {"time", 60}, "мин": {"time", 60},
+	"h": {"time", 3600}, "ч": {"time", 3600},
+	"day": {"time", 86400}, "сут": {"time", 86400},
+	"week": {"time", 604800}, "нед": {"time", 604800},
+
+	"ml": {"volume", 0.001}, "мл": {"volume", 0.001},
+	"l": {"volume", 1}, "л": {"volume", 1},
+	"m3": {"volume", 1000}, "м3": {"volume", 1000},
+	"gal": {"volume", 3.785411784}, "галлон": {"volume", 3.785411784},
+
+	"m/s": {"speed", 1}, "м/с": {"speed", 1},
+	"km/h": {"speed", 1 / 3.6}, "км/ч": {"speed", 1 / 3.6},
+	"mph": {"speed", 0.44704}, "миль/ч": {"speed", 0.44704},
+
+	"b": {"data", 1}, "байт": {"data", 1},
+	"kb": {"data", 1024}, "кб": {"data", 1024},
+	"mb": {"data", 1024 * 1024}, "мб": {"data", 1024 * 1024},
+	"gb": {"data", 1024 * 1024 * 1024}, "гб": {"data", 1024 * 1024 * 1024},
+	"tb": {"data", 1024 * 1024 * 1024 * 1024}, "тб": {"data", 1024 * 1024 * 1024 * 1024},
+}
+
+var temperatureUnits = map[string]string{
+	"c": "c", "°c": "c", "с°": "c", "°с": "c", "celsius": "c", "цельсий": "c",
+	"f": "f", "°f": "f", "fahrenheit": "f", "фаренгейт": "f",
+	"k": "k", "kelvin": "k", "кельвин": "k",
+}
+
+func ConvertUnits(value float64, from, to string) (float64, error) {
+	from = strings.ToLower(strings.TrimSpace(from))
+	to = strings.ToLower(strings.TrimSpace(to))
+
+	fromTemp, fromIsTemp := temperatureUnits[from]
+	toTemp, toIsTemp := temperatureUnits[to]
+	if fromIsTemp || toIsTemp {
+		if !fromIsTemp || !toIsTemp {
+			return 0, fmt.Errorf("нельзя перевести %s в %s", from, to)
+		}
+		return convertTemperature(value, fromTemp, toTemp), nil
+	}
+
+	fromUnit, ok := units[from]
+	if !ok {
+		return 0, fmt.Errorf("неизвестная единица измерения '%s'", from)
+	}
+	toUnit, ok := units[to]
+	if !ok {
+		return 0, fmt.Errorf("неизвестная единица измерения '%s'", to)
+	}
+	if fromUnit.dimension != toUnit.dimension {
+		return 0, fmt.Errorf("нельзя перевести %s в %s", from, to)
+	}
+	return value * fromUnit.factor / toUnit.factor, nil
+}
+
+func convertTemperature(value float64, from, to string) float64 {
+	var celsius float64
+	switch from {
+	case "f":
+		celsius = (value - 32) * 5 / 9
+	case "k":
+		celsius = value - 273.15
+	default:
+		celsius = value
+	}
+
+	switch to {
+	case "f":
+		return celsius*9/5 + 32
+	case "k":
+		return celsius + 273.15
+	default:
+		return celsius
+	}
+}
@@ -0,0 +1,178 @@
+package business
+
+import (
+	"calculator/storage"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const anonymousUser = "anonymous"
+
+type UsageReport struct {
+	User        string                        `json:"user"`
+	Session     storage.TokenUsage            `json:"session"`
+	Today       storage.TokenUsage            `json:"today"`
+	DailyLimit  int                           `json:"dailyLimit"`
+	Remaining   int                           `json:"remaining"`
+	SessionCost float64                       `json:"sessionCost"`
+	TodayCost   float64                       `json:"todayCost"`
+	Currency    string                        `json:"currency"`
+	Users       map[string]storage.TokenUsage `json:"users,omitempty"`
+}
+
+func (i *Interpreter) SetUsageStore(store *storage.UsageStore) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.usage = store
+}
+
+func (i *Interpreter) SetUser(user string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.user = user
+}
+
+func (i *Interpreter) usageUser() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	switch {
+	case i.user != "":
+		return i.user
+	case i.callUsername != "" && !i.remote:
+		return i.callUsername
+	default:
+		return anonymousUser
+	}
+}
+
+func (i *Interpreter) checkQuota() error {
+	i.mu.RLock()
+	store, limit := i.usage, i.config.Usage.DailyTokens
+	anonymous := i.remote && i.user == ""
+	i.mu.RUnlock()
+	if store == nil || limit <= 0 {
+		return nil
+	}
+	if anonymous {
+		return i.errorf("usage.login_required")
+	}
+
+	user := i.usageUser()
+	if used := store.Today(user).Total(); used >= limit {
+		return i.errorf("usage.quota_exceeded", user, used, limit)
+	}
+	return nil
+}
+
+func (i *Interpreter) recordUsage(req ChatRequest, response *ChatResponse) {
+	usage := response.Usage
+	if usage.Total() == 0 {
+		usage = storage.TokenUsage{
+			PromptTokens:     messagesTokens(req.Messages),
+			CompletionTokens: estimateTokens(response.Content),
+		}
+	}
+	usage.Requests = 1
+
+	user := i.usageUser()
+	i.mu.Lock()
+	i.sessionUsage.Add(usage)
+	store := i.usage
+	i.mu.Unlock()
+
+	if store != nil {
+		store.Record(user, usage)
+	}
+}
+
+func (i *Interpreter) Usage() UsageReport {
+	user := i.usageUser()
+
+	i.mu.RLock()
+	store, cfg, remote := i.usage, i.config.Usage, i.remote
+	report := UsageReport{User: user, Session: i.sessionUsage, DailyLimit: cfg.DailyTokens, Currency: cfg.Currency}
+	i.mu.RUnlock()
+
+	if store != nil {
+		report.Today = store.Today(user)
+		if remote && !isUsageAdmin(cfg.Admins, user) {
+			report.Users = map[string]storage.TokenUsage{user: report.Today}
+		} else {
+			report.Users = store.TodayByUser()
+		}
+	}
+	if report.DailyLimit > 0 {
+		report.Remaining = report.DailyLimit - report.Today.Total()
+		if report.Remaining < 0 {
+			report.Remaining = 0
+		}
+	}
+	report.SessionCost = i.usageCost(report.Session)
+	report.TodayCost = i.usageCost(report.Today)
+	return report
+}
+
+func isUsageAdmin(admins []string, user string) bool {
+	for _, admin := range admins {
+		if user != anonymousUser && user == admin {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Interpreter) usageCost(usage storage.TokenUsage) float64 {
+	cfg := i.Config().Usage
+	return float64(usage.PromptTokens)/1000*cfg.PromptPrice + float64(usage.CompletionTokens)/1000*cfg.CompletionPrice
+}
+
+func (i *Interpreter) handleUsageCommand() string {
+	report := i.Usage()
+	priced := report.SessionCost > 0 || report.TodayCost > 0
+
+	line := func(title string, usage storage.TokenUsage, cost float64) string {
+		text := i.sprintf("usage.line", title, usage.Requests, usage.Total(), usage.PromptTokens, usage.CompletionTokens)
+		if priced {
+			text += i.sprintf("usage.cost", cost, report.Currency)
+		}
+		return text
+	}
+
+	lines := []string{
+		i.msg("usage.title"),
+		line(i.msg("usage.session"), report.Session, report.SessionCost),
+	}
+	if report.Users == nil {
+		return strings.Join(lines, "\n")
+	}
+
+	today := line(i.sprintf("usage.today", report.User), report.Today, report.TodayCost)
+	if report.DailyLimit > 0 {
+		today += i.sprintf("usage.limit", report.DailyLimit, report.Remaining)
+	}
+	lines = append(lines, today)
+
+	if len(report.Users) > 1 {
+		lines = append(lines, i.msg("usage.users"))
+		for _, user := range usersByConsumption(report.Users) {
+			usage := report.Users[user]
+			lines = append(lines, fmt.Sprintf("  %s — %s", user, i.sprintf("usage.user_tokens", usage.Total(), usage.Requests)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func usersByConsumption(users map[string]storage.TokenUsage) []string {
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		if users[names[a]].Total() != users[names[b]].Total() {
+			return users[names[a]].Total() > users[names[b]].Total()
+		}
+		return names[a] < names[b]
+	})
+	return names
+}
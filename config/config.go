@@ -0,0 +1,582 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPath    = "config.json"
+	redactedSecret = "******"
+	minSecretLen   = 16
+)
+
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("длительность должна быть строкой вида \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+type LLM struct {
+	Provider string   `json:"provider"`
+	Endpoint string   `json:"endpoint"`
+	Model    string   `json:"model"`
+	APIKey   string   `json:"apiKey,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	Timeout  Duration `json:"timeout"`
+
+	ConversationTokens int `json:"conversationTokens"`
+	MaxToolRounds      int `json:"maxToolRounds"`
+
+	MaxRetries         int      `json:"maxRetries"`
+	RetryDelay         Duration `json:"retryDelay"`
+	RetryMaxDelay      Duration `json:"retryMaxDelay"`
+	BreakerThreshold   float64  `json:"breakerThreshold"`
+	BreakerMinRequests int      `json:"breakerMinRequests"`
+	BreakerCooldown    Duration `json:"breakerCooldown"`
+}
+
+type Cache struct {
+	Path       string   `json:"path"`
+	TTL        Duration `json:"ttl"`
+	MaxEntries int      `json:"maxEntries"`
+	MaxBytes   int      `json:"maxBytes"`
+}
+
+type Usage struct {
+	Path            string   `json:"path"`
+	DailyTokens     int      `json:"dailyTokens"`
+	PromptPrice     float64  `json:"promptPrice"`
+	CompletionPrice float64  `json:"completionPrice"`
+	Currency        string   `json:"currency"`
+	Admins          []string `json:"admins,omitempty"`
+}
+
+type Website struct {
+	ChunkSize int `json:"chunkSize"`
+	MaxChunks int `json:"maxChunks"`
+	Workers   int `json:"workers"`
+	MaxLinks  int `json:"maxLinks"`
+}
+
+type Outbound struct {
+	AllowPrivate     bool     `json:"allowPrivate"`
+	AllowHosts       []string `json:"allowHosts,omitempty"`
+	DenyHosts        []string `json:"denyHosts,omitempty"`
+	AllowNetworks    []string `json:"allowNetworks,omitempty"`
+	MaxResponseBytes int64    `json:"maxResponseBytes"`
+	ContentTypes     []string `json:"contentTypes,omitempty"`
+}
+
+type Config struct {
+	ListenAddr      string   `json:"listenAddr"`
+	JWTSecret       string   `json:"jwtSecret"`
+	CallServerURL   string   `json:"callServerUrl"`
+	LLM             LLM      `json:"llm"`
+	Cache           Cache    `json:"cache"`
+	Usage           Usage    `json:"usage"`
+	Website         Website  `json:"website"`
+	Outbound        Outbound `json:"outbound"`
+	ClassifierRules string   `json:"classifierRules,omitempty"`
+	PromptsDir      string   `json:"promptsDir,omitempty"`
+	SafeDirs        []string `json:"safeDirs,omitempty"`
+	HistoryPath     string   `json:"historyPath"`
+	HTTPTimeout     Duration `json:"httpTimeout"`
+	SessionTimeout  Duration `json:"sessionTimeout"`
+	MaxSessions     int      `json:"maxSessions"`
+	Source          string   `json:"-"`
+}
+
+func Default() *Config {
+	return &Config{
+		ListenAddr:    ":8080",
+		CallServerURL: "http://localhost:8080",
+		LLM: LLM{
+			Provider: "openai",
+			Model:    "deepseek-chat",
+			Timeout:  Duration{60 * time.Second},
+
+			ConversationTokens: 2000,
+			MaxToolRounds:      5,
+
+			MaxRetries:         2,
+			RetryDelay:         Duration{500 * time.Millisecond},
+			RetryMaxDelay:      Duration{10 * time.Second},
+			BreakerThreshold:   0.5,
+			BreakerMinRequests: 5,
+			BreakerCooldown:    Duration{30 * time.Second},
+		},
+		Cache: Cache{
+			Path:       "llm_cache.json",
+			TTL:        Duration{24 * time.Hour},
+			MaxEntries: 500,
+			MaxBytes:   5 << 20,
+		},
+		Usage: Usage{
+			Path:     "usage.json",
+			Currency: "$",
+		},
+		Website: Website{
+			ChunkSize: 8000,
+			MaxChunks: 8,
+			Workers:   3,
+			MaxLinks:  40,
+		},
+		Outbound: Outbound{
+			MaxResponseBytes: 10 << 20,
+			ContentTypes:     []string{"text/", "application/json", "+json", "application/xml", "+xml", "application/javascript"},
+		},
+		HistoryPath:    "history.txt",
+		HTTPTimeout:    Duration{60 * time.Second},
+		SessionTimeout: Duration{30 * time.Minute},
+		MaxSessions:    1000,
+	}
+}
+
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	apply func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"listen", "CALC_LISTEN_ADDR", "адрес, на котором слушает веб-сервер", func(c *Config, v string) error {
+		c.ListenAddr = v
+		return nil
+	}},
+	{"jwt-secret", "CALC_JWT_SECRET", "секрет для подписи JWT", func(c *Config, v string) error {
+		c.JWTSecret = v
+		return nil
+	}},
+	{"call-server", "CALC_CALL_SERVER_URL", "адрес сервера звонков", func(c *Config, v string) error {
+		c.CallServerURL = v
+		return nil
+	}},
+	{"llm", "CALC_LLM_PROVIDER", "провайдер LLM: openai, ollama или mock", func(c *Config, v string) error {
+		c.LLM.Provider = v
+		return nil
+	}},
+	{"llm-endpoint", "CALC_LLM_ENDPOINT", "адрес сервера LLM", func(c *Config, v string) error {
+		c.LLM.Endpoint = v
+		return nil
+	}},
+	{"llm-model", "CALC_LLM_MODEL", "модель LLM", func(c *Config, v string) error {
+		c.LLM.Model = v
+		return nil
+	}},
+	{"llm-api-key", "CALC_LLM_API_KEY", "API-ключ LLM (Bearer)", func(c *Config, v string) error {
+		c.LLM.APIKey = v
+		return nil
+	}},
+	{"llm-username", "CALC_LLM_USERNAME", "имя пользователя LLM (basic auth)", func(c *Config, v string) error {
+		c.LLM.Username = v
+		return nil
+	}},
+	{"llm-password", "CALC_LLM_PASSWORD", "пароль LLM (basic auth)", func(c *Config, v string) error {
+		c.LLM.Password = v
+		return nil
+	}},
+	{"llm-timeout", "CALC_LLM_TIMEOUT", "таймаут запросов к LLM", func(c *Config, v string) error {
+		return parseDuration(&c.LLM.Timeout, v)
+	}},
+	{"llm-history-tokens", "CALC_LLM_HISTORY_TOKENS", "бюджет токенов памяти разговора с AI (0 — без памяти)", func(c *Config, v string) error {
+		tokens, err := strconv.Atoi(v)
+		c.LLM.ConversationTokens = tokens
+		return err
+	}},
+	{"llm-tool-rounds", "CALC_LLM_TOOL_ROUNDS", "максимум раундов вызова инструментов калькулятора (0 — без инструментов)", func(c *Config, v string) error {
+		rounds, err := strconv.Atoi(v)
+		c.LLM.MaxToolRounds = rounds
+		return err
+	}},
+	{"llm-retries", "CALC_LLM_RETRIES", "число повторов запроса к LLM при ответах 5xx и 429", func(c *Config, v string) error {
+		retries, err := strconv.Atoi(v)
+		c.LLM.MaxRetries = retries
+		return err
+	}},
+	{"llm-retry-delay", "CALC_LLM_RETRY_DELAY", "начальная задержка перед повтором запроса к LLM", func(c *Config, v string) error {
+		return parseDuration(&c.LLM.RetryDelay, v)
+	}},
+	{"llm-retry-max-delay", "CALC_LLM_RETRY_MAX_DELAY", "максимальная задержка перед повтором запроса к LLM", func(c *Config, v string) error {
+		return parseDuration(&c.LLM.RetryMaxDelay, v)
+	}},
+	{"llm-breaker-threshold", "CALC_LLM_BREAKER_THRESHOLD", "доля ошибок LLM, после которой запросы временно отклоняются (0 — выключено)", func(c *Config, v string) error {
+		threshold, err := strconv.ParseFloat(v, 64)
+		c.LLM.BreakerThreshold = threshold
+		return err
+	}},
+	{"llm-breaker-min-requests", "CALC_LLM_BREAKER_MIN_REQUESTS", "минимум запросов к LLM для оценки доли ошибок", func(c *Config, v string) error {
+		requests, err := strconv.Atoi(v)
+		c.LLM.BreakerMinRequests = requests
+		return err
+	}},
+	{"llm-breaker-cooldown", "CALC_LLM_BREAKER_COOLDOWN", "сколько ждать перед пробным запросом к недоступному LLM", func(c *Config, v string) error {
+		return parseDuration(&c.LLM.BreakerCooldown, v)
+	}},
+	{"cache-path", "CALC_CACHE_PATH", "файл кэша ответов AI (пусто — без кэша)", func(c *Config, v string) error {
+		c.Cache.Path = v
+		return nil
+	}},
+	{"cache-ttl", "CALC_CACHE_TTL", "время жизни записи в кэше ответов AI", func(c *Config, v string) error {
+		return parseDuration(&c.Cache.TTL, v)
+	}},
+	{"cache-max-entries", "CALC_CACHE_MAX_ENTRIES", "максимум записей в кэше ответов AI (0 — без ограничения)", func(c *Config, v string) error {
+		entries, err := strconv.Atoi(v)
+		c.Cache.MaxEntries = entries
+		return err
+	}},
+	{"cache-max-bytes", "CALC_CACHE_MAX_BYTES", "максимальный размер кэша ответов AI в байтах (0 — без ограничения)", func(c *Config, v string) error {
+		size, err := strconv.Atoi(v)
+		c.Cache.MaxBytes = size
+		return err
+	}},
+	{"usage-path", "CALC_USAGE_PATH", "файл учёта расхода токенов AI (пусто — только в памяти сессии)", func(c *Config, v string) error {
+		c.Usage.Path = v
+		return nil
+	}},
+	{"usage-daily-tokens", "CALC_USAGE_DAILY_TOKENS", "дневной лимит токенов AI на пользователя (0 — без лимита)", func(c *Config, v string) error {
+		tokens, err := strconv.Atoi(v)
+		c.Usage.DailyTokens = tokens
+		return err
+	}},
+	{"usage-prompt-price", "CALC_USAGE_PROMPT_PRICE", "цена 1000 токенов запроса", func(c *Config, v string) error {
+		price, err := strconv.ParseFloat(v, 64)
+		c.Usage.PromptPrice = price
+		return err
+	}},
+	{"usage-completion-price", "CALC_USAGE_COMPLETION_PRICE", "цена 1000 токенов ответа", func(c *Config, v string) error {
+		price, err := strconv.ParseFloat(v, 64)
+		c.Usage.CompletionPrice = price
+		return err
+	}},
+	{"usage-admins", "CALC_USAGE_ADMINS", "пользователи, которым в вебе виден расход всех пользователей, через запятую", func(c *Config, v string) error {
+		c.Usage.Admins = splitList(v)
+		return nil
+	}},
+	{"website-chunk-size", "CALC_WEBSITE_CHUNK_SIZE", "размер части текста страницы для анализа, в символах", func(c *Config, v string) error {
+		size, err := strconv.Atoi(v)
+		c.Website.ChunkSize = size
+		return err
+	}},
+	{"website-max-chunks", "CALC_WEBSITE_MAX_CHUNKS", "сколько частей длинной страницы или файла пересказывать", func(c *Config, v string) error {
+		chunks, err := strconv.Atoi(v)
+		c.Website.MaxChunks = chunks
+		return err
+	}},
+	{"website-workers", "CALC_WEBSITE_WORKERS", "сколько частей пересказывать одновременно", func(c *Config, v string) error {
+		workers, err := strconv.Atoi(v)
+		c.Website.Workers = workers
+		return err
+	}},
+	{"website-max-links", "CALC_WEBSITE_MAX_LINKS", "сколько ссылок страницы передавать на анализ", func(c *Config, v string) error {
+		links, err := strconv.Atoi(v)
+		c.Website.MaxLinks = links
+		return err
+	}},
+	{"outbound-allow-private", "CALC_OUTBOUND_ALLOW_PRIVATE", "разрешить curl и анализу сайтов обращаться к внутренним адресам", func(c *Config, v string) error {
+		allow, err := strconv.ParseBool(v)
+		c.Outbound.AllowPrivate = allow
+		return err
+	}},
+	{"outbound-allow-hosts", "CALC_OUTBOUND_ALLOW_HOSTS", "хосты, к которым разрешены исходящие запросы, через запятую (пусто — любые)", func(c *Config, v string) error {
+		c.Outbound.AllowHosts = splitList(v)
+		return nil
+	}},
+	{"outbound-deny-hosts", "CALC_OUTBOUND_DENY_HOSTS", "хосты, к которым запрещены исходящие запросы, через запятую", func(c *Config, v string) error {
+		c.Outbound.DenyHosts = splitList(v)
+		return nil
+	}},
+	{"outbound-allow-networks", "CALC_OUTBOUND_ALLOW_NETWORKS", "внутренние сети (CIDR), к которым разрешены запросы, через запятую", func(c *Config, v string) error {
+		c.Outbound.AllowNetworks = splitList(v)
+		return nil
+	}},
+	{"outbound-max-bytes", "CALC_OUTBOUND_MAX_BYTES", "максимальный размер ответа на исходящий запрос в байтах", func(c *Config, v string) error {
+		size, err := strconv.ParseInt(v, 10, 64)
+		c.Outbound.MaxResponseBytes = size
+		return err
+	}},
+	{"outbound-content-types", "CALC_OUTBOUND_CONTENT_TYPES", "разрешённые типы содержимого ответа через запятую (пусто — любые)", func(c *Config, v string) error {
+		c.Outbound.ContentTypes = splitList(v)
+		return nil
+	}},
+	{"rules", "CALC_CLASSIFIER_RULES", "файл с правилами локального классификатора запросов", func(c *Config, v string) error {
+		c.ClassifierRules = v
+		return nil
+	}},
+	{"prompts", "CALC_PROMPTS_DIR", "директория с шаблонами системных промптов (*.tmpl)", func(c *Config, v string) error {
+		c.PromptsDir = v
+		return nil
+	}},
+	{"safe-dirs", "CALC_SAFE_DIRS", "дополнительные безопасные директории через " + string(os.PathListSeparator), func(c *Config, v string) error {
+		c.SafeDirs = filepath.SplitList(v)
+		return nil
+	}},
+	{"history", "CALC_HISTORY_PATH", "файл истории команд", func(c *Config, v string) error {
+		c.HistoryPath = v
+		return nil
+	}},
+	{"http-timeout", "CALC_HTTP_TIMEOUT", "таймаут исходящих HTTP-запросов", func(c *Config, v string) error {
+		return parseDuration(&c.HTTPTimeout, v)
+	}},
+	{"session-timeout", "CALC_SESSION_TIMEOUT", "время жизни неактивной веб-сессии", func(c *Config, v string) error {
+		return parseDuration(&c.SessionTimeout, v)
+	}},
+	{"max-sessions", "CALC_MAX_SESSIONS", "максимум одновременных веб-сессий", func(c *Config, v string) error {
+		sessions, err := strconv.Atoi(v)
+		c.MaxSessions = sessions
+		return err
+	}},
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseDuration(target *Duration, value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	target.Duration = parsed
+	return nil
+}
+
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	path := fs.String("config", DefaultPath, "файл конфигурации (JSON)")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = fs.String(s.flag, "", s.usage+" (переменная "+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	explicitPath := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			explicitPath = true
+		}
+	})
+	if envPath := os.Getenv("CALC_CONFIG"); envPath != "" && !explicitPath {
+		*path = envPath
+		explicitPath = true
+	}
+
+	cfg := Default()
+	data, err := os.ReadFile(*path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("ошибка в файле конфигурации %s: %v", *path, err)
+		}
+		cfg.Source = *path
+	case !os.IsNotExist(err) || explicitPath:
+		return nil, fmt.Errorf("не удалось прочитать файл конфигурации: %v", err)
+	}
+
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.apply(cfg, value); err != nil {
+				return nil, fmt.Errorf("переменная %s: %v", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		value, ok := flagValues[f.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.apply(cfg, *value); err != nil {
+					flagErr = fmt.Errorf("флаг -%s: %v", f.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.ListenAddr == "" {
+		problems = append(problems, "не указан адрес веб-сервера (listenAddr)")
+	}
+	if c.JWTSecret != "" && len(c.JWTSecret) < minSecretLen {
+		problems = append(problems, fmt.Sprintf("секрет JWT должен быть не короче %d символов", minSecretLen))
+	}
+	if !isHTTPURL(c.CallServerURL) {
+		problems = append(problems, fmt.Sprintf("некорректный адрес сервера звонков: '%s'", c.CallServerURL))
+	}
+
+	switch c.LLM.Provider {
+	case "openai", "ollama":
+		if c.LLM.Endpoint != "" && !isHTTPURL(c.LLM.Endpoint) {
+			problems = append(problems, fmt.Sprintf("некорректный адрес сервера LLM: '%s'", c.LLM.Endpoint))
+		}
+		if c.LLM.Provider == "openai" && c.LLM.Endpoint == "" {
+			problems = append(problems, "для провайдера openai нужен адрес сервера LLM (llm.endpoint или CALC_LLM_ENDPOINT)")
+		}
+	case "mock":
+	default:
+		problems = append(problems, fmt.Sprintf("неизвестный провайдер LLM '%s'", c.LLM.Provider))
+	}
+	if c.LLM.ConversationTokens < 0 {
+		problems = append(problems, "бюджет памяти разговора не может быть отрицательным")
+	}
+	if c.LLM.MaxToolRounds < 0 {
+		problems = append(problems, "число раундов инструментов не может быть отрицательным")
+	}
+	if c.LLM.MaxRetries < 0 {
+		problems = append(problems, "число повторов запроса к LLM не может быть отрицательным")
+	}
+	if c.LLM.BreakerThreshold < 0 || c.LLM.BreakerThreshold > 1 {
+		problems = append(problems, "доля ошибок LLM (breakerThreshold) должна быть от 0 до 1")
+	}
+	if c.LLM.BreakerMinRequests < 0 {
+		problems = append(problems, "минимум запросов к LLM не может быть отрицательным")
+	}
+	if c.LLM.Password != "" && c.LLM.Username == "" {
+		problems = append(problems, "пароль LLM задан без имени пользователя")
+	}
+
+	if c.Cache.MaxEntries < 0 || c.Cache.MaxBytes < 0 {
+		problems = append(problems, "ограничения кэша ответов AI не могут быть отрицательными")
+	}
+
+	if c.Usage.DailyTokens < 0 {
+		problems = append(problems, "дневной лимит токенов не может быть отрицательным")
+	}
+	if c.Usage.PromptPrice < 0 || c.Usage.CompletionPrice < 0 {
+		problems = append(problems, "цена токенов не может быть отрицательной")
+	}
+
+	if c.Website.ChunkSize <= 0 {
+		problems = append(problems, "размер части страницы (website.chunkSize) должен быть положительным")
+	}
+	if c.Website.MaxChunks <= 0 || c.Website.Workers <= 0 {
+		problems = append(problems, "число частей и обработчиков страницы (website.maxChunks, website.workers) должно быть положительным")
+	}
+	if c.Website.MaxLinks < 0 {
+		problems = append(problems, "число ссылок страницы не может быть отрицательным")
+	}
+
+	if c.Outbound.MaxResponseBytes <= 0 {
+		problems = append(problems, "максимальный размер ответа (outbound.maxResponseBytes) должен быть положительным")
+	}
+	for _, network := range c.Outbound.AllowNetworks {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			problems = append(problems, fmt.Sprintf("некорректная сеть в outbound.allowNetworks: '%s'", network))
+		}
+	}
+
+	if c.MaxSessions <= 0 {
+		problems = append(problems, "максимум веб-сессий (maxSessions) должен быть положительным")
+	}
+	if c.HistoryPath == "" {
+		problems = append(problems, "не указан файл истории (historyPath)")
+	}
+	if c.PromptsDir != "" {
+		if info, err := os.Stat(c.PromptsDir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("директория шаблонов промптов не найдена: '%s'", c.PromptsDir))
+		}
+	}
+	for _, dir := range c.SafeDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("безопасная директория не найдена: '%s'", dir))
+		}
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"llm.timeout", c.LLM.Timeout},
+		{"llm.retryDelay", c.LLM.RetryDelay},
+		{"llm.retryMaxDelay", c.LLM.RetryMaxDelay},
+		{"llm.breakerCooldown", c.LLM.BreakerCooldown},
+		{"httpTimeout", c.HTTPTimeout},
+		{"sessionTimeout", c.SessionTimeout},
+		{"cache.ttl", c.Cache.TTL},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value.Duration <= 0 {
+			problems = append(problems, fmt.Sprintf("таймаут %s должен быть положительным", timeout.name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("некорректная конфигурация:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+func (c *Config) RequireJWTSecret() error {
+	if c.JWTSecret == "" {
+		return errors.New("секрет JWT не задан: укажите jwtSecret в файле конфигурации или переменную CALC_JWT_SECRET")
+	}
+	return nil
+}
+
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.SafeDirs = append([]string(nil), c.SafeDirs...)
+	for _, secret := range []*string{&redacted.JWTSecret, &redacted.LLM.APIKey, &redacted.LLM.Password} {
+		if *secret != "" {
+			*secret = redactedSecret
+		}
+	}
+	return &redacted
+}
+
+func (c *Config) String() string {
+	data, _ := json.MarshalIndent(c.Redacted(), "", "  ")
+	return string(data)
+}
+
+func isHTTPURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
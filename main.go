@@ -2,13 +2,16 @@ package main
 
 import (
 	"calculator/business"
+	"calculator/config"
 	"calculator/presentation"
 	"calculator/storage"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -18,7 +21,7 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-var jwtKey = []byte("verysecretkey")
+var jwtKey []byte
 
 type User struct {
 	Name string
@@ -50,24 +53,107 @@ func NewServer() *Server {
 }
 
 func main() {
-	historyRepo := storage.NewHistoryRepository()
-	interpreter := business.NewInterpreter(historyRepo)
-	webHandler := presentation.NewWebHandler(interpreter)
-	err := historyRepo.Restore()
+	cliMode := flag.Bool("cli", false, "запустить калькулятор в терминале вместо веб-сервера")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	jwtKey = []byte(cfg.JWTSecret)
+
+	llmProvider, err := business.NewLLMProvider(cfg.LLM)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var classifier *business.RuleClassifier
+	if cfg.ClassifierRules != "" {
+		classifier, err = business.LoadRuleClassifier(cfg.ClassifierRules)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var prompts *business.PromptLibrary
+	if cfg.PromptsDir != "" {
+		prompts, err = business.LoadPromptLibrary(cfg.PromptsDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	historyRepo := storage.NewHistoryRepositoryAt(cfg.HistoryPath)
+	err = historyRepo.Restore()
 	if err != nil {
 		fmt.Printf("Ошибка восстановления истории: %v\n", err)
 	}
 
+	var responseCache *storage.ResponseCache
+	if cfg.Cache.Path != "" {
+		responseCache = storage.NewResponseCache(cfg.Cache.Path, cfg.Cache.TTL.Duration, cfg.Cache.MaxEntries, cfg.Cache.MaxBytes)
+		if err := responseCache.Load(); err != nil {
+			fmt.Printf("Ошибка загрузки кэша ответов AI: %v\n", err)
+		}
+	}
+
+	var usageStore *storage.UsageStore
+	if cfg.Usage.Path != "" {
+		usageStore = storage.NewUsageStore(cfg.Usage.Path)
+		if err := usageStore.Load(); err != nil {
+			fmt.Printf("Ошибка загрузки учёта расхода AI: %v\n", err)
+		}
+	}
+
+	newInterpreter := func() *business.Interpreter {
+		interpreter := business.NewInterpreter(historyRepo)
+		interpreter.ApplyConfig(cfg)
+		interpreter.SetLLMProvider(llmProvider)
+		if classifier != nil {
+			interpreter.SetClassifier(classifier)
+		}
+		if prompts != nil {
+			interpreter.SetPrompts(prompts)
+		}
+		if responseCache != nil {
+			interpreter.SetResponseCache(responseCache)
+		}
+		if usageStore != nil {
+			interpreter.SetUsageStore(usageStore)
+		}
+		return interpreter
+	}
+
+	if *cliMode {
+		interpreter := newInterpreter()
+		if current, err := user.Current(); err == nil {
+			interpreter.SetUser(current.Username)
+		}
+		presentation.NewCLI(interpreter, historyRepo).Run()
+		if responseCache != nil {
+			if err := responseCache.Flush(); err != nil {
+				fmt.Printf("Ошибка сохранения кэша ответов AI: %v\n", err)
+			}
+		}
+		return
+	}
+
+	if err := cfg.RequireJWTSecret(); err != nil {
+		log.Fatal(err)
+	}
+
+	sessions := presentation.NewSessionManager(newInterpreter, cfg.SessionTimeout.Duration)
+	sessions.SetMaxSessions(cfg.MaxSessions)
+	webHandler := presentation.NewWebHandler(sessions)
+	webHandler.SetIdentify(usernameFromToken)
+
 	s := NewServer()
 
 	http.Handle("/", http.FileServer(http.Dir("./web")))
 	http.Handle("/webrtc/", http.StripPrefix("/webrtc/", http.FileServer(http.Dir("./signaling/static"))))
 
 	http.HandleFunc("/api/calculate", webHandler.CalculateHandler)
+	http.HandleFunc("/api/calculate/stream", webHandler.StreamHandler)
 	http.HandleFunc("/api/history", webHandler.HistoryHandler)
-
-	http.HandleFunc("/api/calculate", webHandler.CalculateHandler)
-	http.HandleFunc("/api/history", webHandler.HistoryHandler)
+	http.HandleFunc("/api/usage", webHandler.UsageHandler)
 	http.HandleFunc("/api/auth/login", s.loginHandler)
 	http.HandleFunc("/api/session", s.sessionHandler)
 	http.HandleFunc("/api/session/accept", s.acceptHandler)
@@ -113,10 +199,18 @@ func main() {
 		log.Printf("=== ДАННЫЕ ОТПРАВЛЕНЫ ===")
 	})
 
-	log.Println("🚀 Сервер запущен на :8080")
-	log.Println("📊 Калькулятор: http://localhost:8080")
-	log.Println("📞 WebRTC звонки: http://localhost:8080/webrtc/")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	baseURL := localURL(cfg.ListenAddr)
+	log.Printf("🚀 Сервер запущен на %s", cfg.ListenAddr)
+	log.Printf("📊 Калькулятор: %s", baseURL)
+	log.Printf("📞 WebRTC звонки: %s/webrtc/", baseURL)
+	log.Fatal(http.ListenAndServe(cfg.ListenAddr, nil))
+}
+
+func localURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "http://localhost" + addr
+	}
+	return "http://" + addr
 }
 
 func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -140,6 +234,26 @@ func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"token": tokenStr})
 }
 
+func usernameFromToken(r *http.Request) string {
+	tokenStr := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if tokenStr == "" || tokenStr == r.Header.Get("Authorization") {
+		return ""
+	}
+
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	})
+	if err != nil || !token.Valid {
+		return ""
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	username, _ := claims["username"].(string)
+	return username
+}
+
 func (s *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
 	tokenStr := r.URL.Query().Get("token")
 	if tokenStr == "" {
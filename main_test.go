@@ -1,17 +1,36 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"calculator/business"
+	"calculator/config"
+	"calculator/presentation"
 	"calculator/storage"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+func allowLoopback(interpreter *business.Interpreter) {
+	cfg := config.Default()
+	cfg.Outbound.AllowPrivate = true
+	interpreter.ApplyConfig(cfg)
+}
+
 func TestWebsiteOpening(t *testing.T) {
 	fmt.Println("🌐 ТЕСТИРОВАНИЕ ОТКРЫТИЯ САЙТОВ")
 	fmt.Println("═══════════════════════════════════════════════════════════")
@@ -138,6 +157,7 @@ func TestWebsiteAnalysis(t *testing.T) {
 
 	historyRepo := storage.NewHistoryRepository()
 	interpreter := business.NewInterpreter(historyRepo)
+	allowLoopback(interpreter)
 
 	t.Run("analyze website content", func(t *testing.T) {
 		command := "расскажи о содержимом сайта " + mockServer.URL
@@ -169,6 +189,92 @@ func TestWebsiteAnalysis(t *testing.T) {
 	fmt.Println("═══════════════════════════════════════════════════════════")
 }
 
+func TestHTMLExtraction(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><title>Курсы &amp; цены</title><style>body { color: red }</style>
+<script>var tracking = "<p>не текст</p>";</script></head>
+<body>
+<header><a href="/">Логотип</a></header>
+<nav class="menu"><a href="/about">О нас</a></nav>
+<div class="cookie-banner">Мы используем cookies</div>
+<main>
+  <h1>Обмен <b>валют</b></h1>
+  <p>Курс обновляется   каждый
+  час.</p>
+  <!-- <p>скрытый комментарий</p> -->
+  <h2>Таблица</h2>
+  <table><tr><th>Валюта</th><th>Курс</th></tr><tr><td>USD</td><td>91,5</td></tr></table>
+  <ul><li>Без комиссии</li><li>Онлайн</li></ul>
+  <p>Подробнее в <a href="/rules#top">правилах</a> и <a href='https://example.org/faq'>FAQ</a>.</p>
+</main>
+<aside>Реклама</aside>
+<footer>© 2024</footer>
+</body></html>`
+
+	content := business.ExtractPage(page, "https://bank.example/rates/")
+	if content.Title != "Курсы & цены" {
+		t.Errorf("Неверный заголовок: %q", content.Title)
+	}
+	if strings.Join(content.Headings, "; ") != "Обмен валют; Таблица" {
+		t.Errorf("Неверные разделы: %v", content.Headings)
+	}
+	for _, expected := range []string{"# Обмен валют", "Курс обновляется каждый час.", "| USD | 91,5 |", "- Без комиссии", "Подробнее в правилах и FAQ."} {
+		if !strings.Contains(content.Text, expected) {
+			t.Errorf("В тексте нет %q:\n%s", expected, content.Text)
+		}
+	}
+	for _, unexpected := range []string{"color", "tracking", "не текст", "скрытый", "cookies", "Логотип", "О нас", "Реклама", "2024"} {
+		if strings.Contains(content.Text, unexpected) {
+			t.Errorf("В тексте не должно быть %q:\n%s", unexpected, content.Text)
+		}
+	}
+	if len(content.Tables) != 1 || len(content.Tables[0]) != 2 || content.Tables[0][1][1] != "91,5" {
+		t.Errorf("Неверно разобрана таблица: %v", content.Tables)
+	}
+	if len(content.Links) != 2 || content.Links[0].URL != "https://bank.example/rules" || content.Links[1].Text != "FAQ" {
+		t.Errorf("Неверно разобраны ссылки: %v", content.Links)
+	}
+
+	wrapped := business.ExtractPage(`<html><body class="has-sidebar"><div id="page" class="with-sidebar">
+<p>Основной текст страницы</p><div class="sidebar">Виджет погоды</div></div></body></html>`, "")
+	if !strings.Contains(wrapped.Text, "Основной текст страницы") || strings.Contains(wrapped.Text, "Виджет погоды") {
+		t.Errorf("Обертка с классом sidebar не должна скрывать содержимое страницы: %q", wrapped.Text)
+	}
+	wrapped = business.ExtractPage(`<body><div class="layout sidebar"><main><p>Текст статьи</p></main><div class="sidebar">Виджет</div></div></body>`, "")
+	if wrapped.Text != "Текст статьи" {
+		t.Errorf("Родитель основного содержимого не должен пропускаться: %q", wrapped.Text)
+	}
+
+	legacy := business.ExtractPage("<p>Привет</p><SCRIPT>\xcf\xf0\xe8\xe2\xe5\xf2 var x = 1;</Script><p>после \xef\xee\xea\xe0</p>", "")
+	if !strings.Contains(legacy.Text, "Привет") || !strings.Contains(legacy.Text, "после") || strings.Contains(legacy.Text, "var x") {
+		t.Errorf("Неверно разобрана страница не в UTF-8: %q", legacy.Text)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	allowLoopback(interpreter)
+	mock := business.NewMockProvider()
+	interpreter.SetLLMProvider(mock)
+	if _, err := interpreter.Execute("проанализируй " + server.URL); err != nil {
+		t.Fatalf("Анализ сайта не удался: %v", err)
+	}
+	requests := mock.Requests()
+	prompt := requests[len(requests)-1].Messages[1].Content
+	for _, expected := range []string{"Заголовок: Курсы & цены", "Разделы: Обмен валют; Таблица", "| USD | 91,5 |", "- FAQ: https://example.org/faq"} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("В запросе к AI нет %q:\n%s", expected, prompt)
+		}
+	}
+	if strings.Contains(prompt, "<") {
+		t.Errorf("В запрос к AI попала разметка:\n%s", prompt)
+	}
+}
+
 func TestWebRTCDebug(t *testing.T) {
 	fmt.Println("🔧 ДИАГНОСТИКА ЗВОНКОВ")
 	fmt.Println("═══════════════════════════════════════════════════════════")
@@ -277,6 +383,7 @@ func TestCurlCommands(t *testing.T) {
 
 	historyRepo := storage.NewHistoryRepository()
 	interpreter := business.NewInterpreter(historyRepo)
+	allowLoopback(interpreter)
 
 	t.Run("simple curl", func(t *testing.T) {
 		result, err := interpreter.Execute("curl " + server.URL)
@@ -310,6 +417,146 @@ func TestCurlCommands(t *testing.T) {
 			fmt.Printf("✅ Curl присваивание работает\n")
 		}
 	})
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/final", http.StatusFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		username, password, _ := r.BasicAuth()
+		w.Header().Set("X-Echo", "yes")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s %s|%s|%s|%s:%s", r.Method, r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("X-Token"), body, username, password)
+	}))
+	defer echo.Close()
+
+	t.Run("curl options", func(t *testing.T) {
+		tests := []struct {
+			command  string
+			contains []string
+			excludes []string
+		}{
+			{"curl -X PUT -H 'X-Token: a b' -d 'x=1' -d y=2 " + echo.URL + "/items", []string{"HTTP/1.1 201 Created", "X-Echo: yes", "PUT /items application/x-www-form-urlencoded|a b|x=1&y=2|"}, nil},
+			{`curl --json "{\"a\": 1}" -u alice:secret ` + echo.URL, []string{"POST / application/json||{\"a\": 1}|alice:secret"}, nil},
+			{"curl -s " + echo.URL + "/quiet", []string{"GET /quiet"}, []string{"HTTP/1.1"}},
+			{"curl -I " + echo.URL, []string{"HTTP/1.1 201 Created", "X-Echo: yes"}, []string{"HEAD /"}},
+			{"curl " + echo.URL + "/redirect", []string{"302 Found", "Location: /final"}, []string{"GET /final"}},
+			{"curl -sL --max-time 5 " + echo.URL + "/redirect", []string{"GET /final"}, nil},
+		}
+		for _, test := range tests {
+			result, err := interpreter.Execute(test.command)
+			if err != nil {
+				t.Errorf("%s: %v", test.command, err)
+				continue
+			}
+			text := fmt.Sprint(result)
+			for _, expected := range test.contains {
+				if !strings.Contains(text, expected) {
+					t.Errorf("%s: в ответе нет %q:\n%s", test.command, expected, text)
+				}
+			}
+			for _, unexpected := range test.excludes {
+				if strings.Contains(text, unexpected) {
+					t.Errorf("%s: в ответе не должно быть %q:\n%s", test.command, unexpected, text)
+				}
+			}
+		}
+
+		for _, command := range []string{"curl 'unterminated " + echo.URL, "curl --bogus " + echo.URL, "curl -H", "curl -o /etc/curl_test " + echo.URL} {
+			if _, err := interpreter.Execute(command); err == nil {
+				t.Errorf("%s: ожидалась ошибка", command)
+			}
+		}
+
+		if _, err := interpreter.Execute("reply = curl -X POST -d 'q=1' " + echo.URL + "/form"); err != nil {
+			t.Fatalf("Присваивание curl с параметрами не удалось: %v", err)
+		}
+		if value, _ := interpreter.Execute("reply"); fmt.Sprint(value) != "POST /form application/x-www-form-urlencoded||q=1|:" {
+			t.Errorf("В переменную должно сохраняться тело ответа, получено %q", value)
+		}
+	})
+
+	t.Run("structured response", func(t *testing.T) {
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Rate", "42")
+			fmt.Fprint(w, `{"items": [{"price": 12.5}, {"price": 3}], "rates": {"USD": 91.5}, "ok": true}`)
+		}))
+		defer api.Close()
+
+		if _, err := interpreter.Execute("api = curl -s " + api.URL); err != nil {
+			t.Fatalf("Присваивание curl не удалось: %v", err)
+		}
+		tests := []struct {
+			expression string
+			expected   interface{}
+		}{
+			{"api.body.items[0].price * 2", 25.0},
+			{"api.body.items[1].price + api.body.items[0].price", 15.5},
+			{`jsonpath(api, "$.rates.USD")`, 91.5},
+			{`jsonpath(api, "$.rates.USD") * 10 + 1`, 916.0},
+			{`JSONPath(api, "$.rates.USD") * 2`, 183.0},
+			{"api.status", 200.0},
+			{"api.status == 200", true},
+			{`api.headers["x-rate"]`, "42"},
+			{"api.body.ok", true},
+		}
+		for _, test := range tests {
+			result, err := interpreter.Execute(test.expression)
+			if err != nil {
+				t.Errorf("%s: %v", test.expression, err)
+				continue
+			}
+			if result != test.expected {
+				t.Errorf("%s: ожидалось %v, получено %v", test.expression, test.expected, result)
+			}
+		}
+
+		for _, expression := range []string{"api.body.items[5].price * 2", "api.body.missing + 1", `jsonpath(api, "$.rates.EUR") * 2`, "api.body * 2"} {
+			if _, err := interpreter.Execute(expression); err == nil {
+				t.Errorf("%s: ожидалась ошибка", expression)
+			}
+		}
+	})
+
+	t.Run("output file", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := config.Default()
+		cfg.Outbound.AllowPrivate = true
+		cfg.SafeDirs = []string{dir}
+		local := business.NewInterpreter(historyRepo)
+		local.ApplyConfig(cfg)
+
+		target := filepath.Join(dir, "reply.json")
+		if _, err := local.Execute("curl -s -o " + target + " " + server.URL); err != nil {
+			t.Fatalf("Сохранение ответа в файл не удалось: %v", err)
+		}
+		if data, _ := os.ReadFile(target); !strings.Contains(string(data), "test response") {
+			t.Errorf("В файле нет ответа сервера: %q", data)
+		}
+
+		outside := t.TempDir()
+		os.Symlink(outside, filepath.Join(dir, "link"))
+		for _, command := range []string{
+			"curl -s -o " + target + " " + server.URL,
+			"curl -s -o " + filepath.Join(dir, "link", "reply.json") + " " + server.URL,
+		} {
+			if _, err := local.Execute(command); err == nil {
+				t.Errorf("%s: ожидалась ошибка", command)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outside, "reply.json")); err == nil {
+			t.Errorf("Файл не должен записываться через символическую ссылку")
+		}
+
+		remote := business.NewInterpreter(historyRepo)
+		remote.ApplyConfig(cfg)
+		remote.SetRemote(true)
+		if _, err := remote.Execute("curl -s -o " + filepath.Join(dir, "web.json") + " " + server.URL); err == nil {
+			t.Errorf("Веб-сессия не должна сохранять ответы в файл")
+		}
+	})
 }
 
 func TestFileOperations(t *testing.T) {
@@ -397,3 +644,1648 @@ func TestErrorHandling(t *testing.T) {
 	})
 }
 
+func TestResultReferences(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+	interpreter.SetLLMProvider(business.NewMockProvider())
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"2+3", 5.0},
+		{"ans*2", 10.0},
+		{"_1+_2", 15.0},
+		{"_ - 5", 10.0},
+		{"_2", 10.0},
+	}
+
+	for _, test := range tests {
+		result, err := interpreter.Execute(test.input)
+		if err != nil {
+			t.Errorf("Ошибка при вычислении %s: %v", test.input, err)
+		} else if result != test.expected {
+			t.Errorf("%s = %v, ожидалось %v", test.input, result, test.expected)
+		} else {
+			fmt.Printf("✅ %s = %v\n", test.input, result)
+		}
+	}
+
+	if _, err := interpreter.Execute("_99 + 1"); err == nil {
+		t.Errorf("Ссылка на несуществующий результат должна возвращать ошибку")
+	}
+
+	if result, err := interpreter.ExecuteResult(context.Background(), "привет мир"); err != nil || result.Kind != business.ResultLLMAnswer || result.Ref != "" {
+		t.Fatalf("Ответ AI не должен получать ссылку: %+v, %v", result, err)
+	}
+	if result, err := interpreter.Execute("ans*2"); err != nil || result != 20.0 {
+		t.Errorf("После ответа AI ans должен ссылаться на последнее вычисление: %v, %v", result, err)
+	}
+	if result, err := interpreter.Execute("_6"); err != nil || result != 20.0 {
+		t.Errorf("_6 = %v, %v; ожидалось 20", result, err)
+	}
+
+	if _, err := interpreter.Execute("ans = 3"); err == nil {
+		t.Errorf("Присваивание ans должно отклоняться")
+	}
+	if result, err := interpreter.Execute("ans"); err != nil || result != 20.0 {
+		t.Errorf("ans не должен затеняться переменной: %v, %v", result, err)
+	}
+}
+
+func TestWebSessionIsolation(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	sessions := presentation.NewSessionManager(func() *business.Interpreter {
+		return business.NewInterpreter(historyRepo)
+	}, time.Minute)
+	handler := presentation.NewWebHandler(sessions)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/calculate", handler.CalculateHandler)
+	mux.HandleFunc("/api/history", handler.HistoryHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	newClient := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatalf("Не удалось создать cookie jar: %v", err)
+		}
+		return &http.Client{Jar: jar}
+	}
+	calculate := func(client *http.Client, command string) string {
+		body, _ := json.Marshal(map[string]string{"command": command})
+		resp, err := client.Post(server.URL+"/api/calculate", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Запрос %s не удался: %v", command, err)
+		}
+		defer resp.Body.Close()
+		var result struct {
+			Success bool   `json:"success"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result.Message
+	}
+
+	alice := newClient()
+	bob := newClient()
+
+	calculate(alice, "x = 5")
+	calculate(bob, "x = 7")
+
+	if got := calculate(alice, "x*2"); got != "10" {
+		t.Errorf("Сессия alice: x*2 = %s, ожидалось 10", got)
+	}
+	if got := calculate(bob, "x*2"); got != "14" {
+		t.Errorf("Сессия bob: x*2 = %s, ожидалось 14", got)
+	}
+	if sessions.Count() != 2 {
+		t.Errorf("Ожидалось 2 сессии, получено %d", sessions.Count())
+	} else {
+		fmt.Printf("✅ Переменные изолированы между сессиями\n")
+	}
+
+	history := func(client *http.Client) []string {
+		resp, err := client.Get(server.URL + "/api/history")
+		if err != nil {
+			t.Fatalf("Запрос истории не удался: %v", err)
+		}
+		defer resp.Body.Close()
+		var commands []string
+		json.NewDecoder(resp.Body).Decode(&commands)
+		return commands
+	}
+	if got := strings.Join(history(alice), "; "); got != "x = 5; x*2" {
+		t.Errorf("История alice содержит чужие команды: %s", got)
+	}
+	if got := calculate(bob, "history"); strings.Contains(got, "x = 5") {
+		t.Errorf("Команда history показывает чужие команды: %s", got)
+	}
+	if got := history(newClient()); len(got) != 0 || sessions.Count() != 2 {
+		t.Errorf("Запрос истории без сессии не должен создавать сессию: %v, сессий %d", got, sessions.Count())
+	}
+
+	body, _ := json.Marshal(map[string]string{"command": "1 + 1"})
+	resp, err := http.Post(server.URL+"/api/calculate", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Запрос без сессии не удался: %v", err)
+	}
+	resp.Body.Close()
+	for _, cookie := range resp.Cookies() {
+		if cookie.MaxAge != 0 || !cookie.Expires.IsZero() {
+			t.Errorf("Cookie сессии не должна истекать раньше серверного таймаута бездействия: %s", cookie)
+		}
+	}
+
+	sessions.SetMaxSessions(2)
+	calculate(newClient(), "1 + 1")
+	if sessions.Count() != 2 {
+		t.Errorf("Число сессий должно быть ограничено: %d", sessions.Count())
+	}
+}
+
+func TestConcurrentExecute(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for worker := 0; worker < 20; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			variable := fmt.Sprintf("v%d", worker)
+			for n := 0; n < 10; n++ {
+				if _, err := interpreter.Execute(fmt.Sprintf("%s = %d", variable, n)); err != nil {
+					errs <- err
+					continue
+				}
+				result, err := interpreter.Execute(variable + " * 2")
+				if err != nil {
+					errs <- err
+				} else if result != float64(n*2) {
+					errs <- fmt.Errorf("%s * 2 = %v, ожидалось %d", variable, result, n*2)
+				}
+				interpreter.Execute("ans + 1")
+				interpreter.VariableNames()
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if count := interpreter.ResultCount(); count < 400 {
+		t.Errorf("Ожидалось не меньше 400 сохраненных результатов, получено %d", count)
+	} else {
+		fmt.Printf("✅ Параллельное выполнение: %d результатов\n", count)
+	}
+}
+
+func TestExecuteContextCancellation(t *testing.T) {
+	requestStarted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+	allowLoopback(interpreter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-requestStarted
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := interpreter.ExecuteContext(ctx, "curl "+server.URL)
+	if err == nil {
+		t.Fatalf("Отмененный curl запрос должен возвращать ошибку")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Отмена заняла слишком много времени: %v", elapsed)
+	} else {
+		fmt.Printf("✅ Curl запрос отменен: %v\n", err)
+	}
+
+	if _, err := interpreter.ExecuteContext(ctx, "2+2"); err == nil {
+		t.Errorf("Вычисление с отмененным контекстом должно возвращать ошибку")
+	}
+}
+
+func TestExecuteResult(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+
+	tests := []struct {
+		input string
+		kind  business.ResultKind
+		route string
+		text  string
+	}{
+		{"2+2", business.ResultNumber, business.RouteCalculation, "4"},
+		{"5>3", business.ResultBool, business.RouteCalculation, "true"},
+		{"y = 10/4", business.ResultNumber, business.RouteAssignment, "2.5"},
+		{"history", business.ResultList, business.RouteHistory, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, err := interpreter.ExecuteResult(context.Background(), test.input)
+			if err != nil {
+				t.Fatalf("Ошибка при выполнении %s: %v", test.input, err)
+			}
+			if result.Kind != test.kind || result.Route != test.route {
+				t.Errorf("%s: kind=%s route=%s, ожидалось kind=%s route=%s", test.input, result.Kind, result.Route, test.kind, test.route)
+			}
+			if test.text != "" && result.Text != test.text {
+				t.Errorf("%s: text=%q, ожидалось %q", test.input, result.Text, test.text)
+			}
+		})
+	}
+
+	result, _ := interpreter.ExecuteResult(context.Background(), "1+1")
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Не удалось сериализовать результат: %v", err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	if decoded["kind"] != "number" || decoded["value"] != 2.0 || decoded["ref"] != "_4" {
+		t.Errorf("Неожиданный JSON результата: %s", data)
+	} else {
+		fmt.Printf("✅ Типизированный результат: %s\n", data)
+	}
+}
+
+func TestCustomCommandRegistration(t *testing.T) {
+	err := business.RegisterCommand(business.Command{
+		Name:     "ping",
+		Aliases:  []string{"ping", "пинг"},
+		Help:     "проверка связи",
+		Priority: 850,
+		Kind:     business.ResultText,
+		Handle: func(ctx context.Context, i *business.Interpreter, input string) (interface{}, bool, error) {
+			return "pong", true, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Не удалось зарегистрировать команду: %v", err)
+	}
+	t.Cleanup(func() {
+		business.UnregisterCommand("ping")
+	})
+
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+
+	result, err := interpreter.ExecuteResult(context.Background(), "пинг")
+	if err != nil {
+		t.Fatalf("Ошибка выполнения команды: %v", err)
+	}
+	if result.Text != "pong" || result.Route != "ping" {
+		t.Errorf("Ожидался ответ pong от ping, получено %q от %s", result.Text, result.Route)
+	}
+
+	help, err := interpreter.ExecuteResult(context.Background(), "help")
+	if err != nil {
+		t.Fatalf("Ошибка выполнения help: %v", err)
+	}
+	if !strings.Contains(help.Text, "ping | пинг — проверка связи") {
+		t.Errorf("help не содержит зарегистрированную команду: %s", help.Text)
+	} else {
+		fmt.Printf("✅ Пользовательская команда зарегистрирована и видна в help\n")
+	}
+
+	if err := business.RegisterCommand(business.Command{Name: "broken", Aliases: []string{"broken"}}); err == nil {
+		t.Errorf("Команда без обработчика не должна регистрироваться")
+	}
+}
+
+func TestLocalization(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+	interpreter.SetLLMProvider(business.NewMockProvider())
+
+	routes := func(locale string) map[string]string {
+		interpreter.Execute("language " + locale)
+		got := map[string]string{}
+		for _, input := range []string{"display 2", "play", "включи", "context"} {
+			result, err := interpreter.ExecuteResult(context.Background(), input)
+			if err != nil {
+				t.Fatalf("%s: %v", input, err)
+			}
+			got[input] = result.Route
+		}
+		return got
+	}
+	if got := routes("en"); got["display 2"] != business.RouteLLM || got["context"] != business.RouteLLM || got["play"] != business.RouteOpen || got["включи"] != business.RouteLLM {
+		t.Errorf("Ключевые слова en должны совпадать по границам слов и только для en: %v", got)
+	}
+	if got := routes("ru"); got["play"] != business.RouteLLM || got["включи"] != business.RouteOpen {
+		t.Errorf("В сессии ru английские ключевые слова не должны действовать: %v", got)
+	}
+
+	if _, err := interpreter.Execute("5 / 0"); err == nil || err.Error() != "деление на ноль" {
+		t.Errorf("Ожидалась русская ошибка, получено: %v", err)
+	}
+
+	if _, err := interpreter.Execute("language en"); err != nil {
+		t.Fatalf("Не удалось переключить язык: %v", err)
+	}
+	if _, err := interpreter.Execute("5 / 0"); err == nil || err.Error() != "division by zero" {
+		t.Errorf("Ожидалась английская ошибка, получено: %v", err)
+	}
+
+	if _, err := interpreter.Execute("язык de"); err == nil {
+		t.Errorf("Неподдерживаемый язык должен возвращать ошибку")
+	}
+
+	tests := []struct {
+		header   string
+		expected business.Locale
+	}{
+		{"en-US,en;q=0.9,ru;q=0.8", business.LocaleEn},
+		{"de-DE,ru;q=0.5,en;q=0.3", business.LocaleRu},
+		{"fr", business.DefaultLocale},
+	}
+	for _, test := range tests {
+		if locale := business.ParseAcceptLanguage(test.header); locale != test.expected {
+			t.Errorf("Accept-Language %q: получено %s, ожидалось %s", test.header, locale, test.expected)
+		}
+	}
+	fmt.Printf("✅ Сообщения переключаются между ru и en\n")
+}
+
+func TestExplain(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+
+	result, err := interpreter.Execute("explain 5 / 0")
+	if err != nil {
+		t.Fatalf("explain вернул ошибку: %v", err)
+	}
+	explanation, ok := result.(*business.Explanation)
+	if !ok {
+		t.Fatalf("Ожидалось объяснение, получено %T", result)
+	}
+	if explanation.Route != business.RouteCalculation {
+		t.Errorf("Маршрут: %s, ожидалось %s", explanation.Route, business.RouteCalculation)
+	}
+	if len(explanation.Steps) == 0 || explanation.Steps[0].Error != "деление на ноль" {
+		t.Errorf("Шаги вычисления должны содержать ошибку деления на ноль: %+v", explanation.Steps)
+	}
+	if interpreter.ResultCount() != 0 {
+		t.Errorf("explain не должен вычислять и сохранять результат")
+	}
+
+	sessions := presentation.NewSessionManager(func() *business.Interpreter {
+		return business.NewInterpreter(historyRepo)
+	}, time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(presentation.NewWebHandler(sessions).CalculateHandler))
+	defer server.Close()
+
+	body, _ := json.Marshal(map[string]interface{}{"command": "https://example.com", "explain": true})
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Запрос не удался: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Result struct {
+			Route string               `json:"route"`
+			Value business.Explanation `json:"value"`
+		} `json:"result"`
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+	if response.Result.Route != business.RouteExplain || response.Result.Value.Route != business.RouteOpenLink {
+		t.Errorf("API explain: получено %+v", response.Result)
+	}
+	if !response.Result.Value.Fallthrough {
+		t.Errorf("open-link должен отмечаться как маршрут с запасными вариантами")
+	}
+	fmt.Printf("✅ Режим explain показывает маршрут и шаги\n")
+}
+
+func TestRuleClassifier(t *testing.T) {
+	classifier, err := business.LoadRuleClassifier("business/classifier_rules.json")
+	if err != nil {
+		t.Fatalf("Не удалось загрузить правила: %v", err)
+	}
+
+	tests := []struct {
+		input     string
+		expected  string
+		confident bool
+	}{
+		{"проанализируй https://example.com", "open_website", true},
+		{"открой сайт example.com и дай сводку", "open_website", true},
+		{"summarize https://go.dev/doc", "open_website", true},
+		{"(2 + 3) * 4", "calculation", true},
+		{"посчитай налог с 1000", "calculation", true},
+		{"что такое интеграл", "information", true},
+		{"расскажи о чем https://example.com и почему", "open_website", true},
+		{"https://example.com", "", false},
+		{"посчитай что на https://example.com", "open_website", false},
+	}
+	for _, test := range tests {
+		classificationType, confidence, margin := classifier.Classify(test.input)
+		if classificationType != test.expected {
+			t.Errorf("%q: тип %q, ожидался %q", test.input, classificationType, test.expected)
+		}
+		if confident := classifier.Confident(confidence, margin); confident != test.confident {
+			t.Errorf("%q: уверенность %.2f, отрыв %.2f, порог %.2f", test.input, confidence, margin, classifier.Threshold)
+		}
+	}
+	if _, confidence, margin := classifier.Classify("посчитай что на https://example.com"); confidence != 0.9 || math.Abs(margin-0.1) > 1e-9 {
+		t.Errorf("Уверенность должна быть оценкой лучшего правила, а отрыв — разницей с другим типом: %.2f, %.2f", confidence, margin)
+	}
+
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	mock := business.NewMockProvider()
+	interpreter.SetLLMProvider(mock)
+	result, err := interpreter.ExecuteResult(context.Background(), "открой сайт яндекса без www. и дай сводку")
+	if err != nil || len(result.Warnings) > 0 {
+		t.Errorf("Запрос без адреса сайта должен уйти в AI: %v %v", err, result)
+	}
+	if requests := mock.Requests(); len(requests) == 0 || requests[0].Purpose != business.LLMPurposeClassify {
+		t.Errorf("Запрос без адреса сайта должен классифицироваться через AI: %+v", requests)
+	}
+
+	invalid := []string{
+		`{"threshold": 0.5, "rules": [{"type": "unknown", "keywords": ["x"], "confidence": 0.5}]}`,
+		`{"threshold": 0.5, "rules": [{"type": "calculation", "pattern": "(", "confidence": 0.5}]}`,
+		`{"threshold": 0.5, "rules": [{"type": "calculation", "confidence": 0.5}]}`,
+		`{"threshold": 2, "rules": []}`,
+		`{"threshold": 0.5, "minMargin": -0.1, "rules": []}`,
+	}
+	for _, config := range invalid {
+		if _, err := business.NewRuleClassifier([]byte(config)); err == nil {
+			t.Errorf("Ожидалась ошибка для конфигурации %s", config)
+		}
+	}
+	fmt.Printf("✅ Локальный классификатор работает без сети\n")
+}
+
+func TestLLMProviders(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+	mock := business.NewMockProvider()
+	interpreter.SetLLMProvider(mock)
+
+	result, err := interpreter.ExecuteResult(context.Background(), "столица России")
+	if err != nil {
+		t.Fatalf("Mock-провайдер вернул ошибку: %v", err)
+	}
+	if result.Kind != business.ResultLLMAnswer || result.Text != "[mock] столица России" {
+		t.Errorf("Неожиданный ответ mock-провайдера: %+v", result)
+	}
+	if requests := mock.Requests(); len(requests) != 1 || requests[0].Purpose != business.LLMPurposeAnswer {
+		t.Errorf("Mock-провайдер должен получить один запрос ответа, получено: %+v", requests)
+	}
+
+	openAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"bad credentials"}}`))
+			return
+		}
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, `{"model":%q,"choices":[{"message":{"content":"openai: %d"}}]}`, req.Model, len(req.Messages))
+	}))
+	defer openAIServer.Close()
+
+	ollamaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":"ollama ok"}}`))
+	}))
+	defer ollamaServer.Close()
+
+	tests := []struct {
+		config   config.LLM
+		expected string
+		err      string
+	}{
+		{config.LLM{Provider: "openai", Endpoint: openAIServer.URL, Model: "m", Username: "user", Password: "secret"}, "openai: 2", ""},
+		{config.LLM{Provider: "openai", Endpoint: openAIServer.URL, Model: "m", Username: "user", Password: "wrong"}, "", "401"},
+		{config.LLM{Provider: "ollama", Endpoint: ollamaServer.URL, Model: "llama3"}, "ollama ok", ""},
+	}
+	for _, test := range tests {
+		provider, err := business.NewLLMProvider(test.config)
+		if err != nil {
+			t.Fatalf("Не удалось создать провайдер %s: %v", test.config.Provider, err)
+		}
+		interpreter := business.NewInterpreter(historyRepo)
+		interpreter.SetLLMProvider(provider)
+
+		answer, err := interpreter.SendTextToDeepSeek(context.Background(), "привет")
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: ожидалась ошибка с %q, получено: %v", test.config.Provider, test.err, err)
+			}
+			continue
+		}
+		if err != nil || answer != test.expected {
+			t.Errorf("%s: получено %q (%v), ожидалось %q", test.config.Provider, answer, err, test.expected)
+		}
+	}
+
+	if _, err := business.NewLLMProvider(config.LLM{Provider: "unknown"}); err == nil {
+		t.Errorf("Неизвестный провайдер должен возвращать ошибку")
+	}
+	fmt.Printf("✅ Провайдеры LLM переключаются через конфигурацию\n")
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	fileConfig := `{
+		"listenAddr": ":9000",
+		"jwtSecret": "file-secret-0123456789",
+		"llm": {"provider": "mock", "model": "file-model", "username": "user", "password": "file-password"},
+		"httpTimeout": "5s"
+	}`
+	if err := os.WriteFile(path, []byte(fileConfig), 0644); err != nil {
+		t.Fatalf("Не удалось записать конфигурацию: %v", err)
+	}
+	t.Setenv("CALC_LLM_MODEL", "env-model")
+	t.Setenv("CALC_LISTEN_ADDR", ":9050")
+	t.Setenv("CALC_LLM_USERNAME", "")
+	t.Setenv("CALC_JWT_SECRET", "")
+
+	cfg, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path, "-listen", ":9100"})
+	if err != nil {
+		t.Fatalf("Не удалось загрузить конфигурацию: %v", err)
+	}
+	if cfg.ListenAddr != ":9100" {
+		t.Errorf("Флаг должен переопределять env и файл: listenAddr = %s", cfg.ListenAddr)
+	}
+	if cfg.LLM.Model != "env-model" {
+		t.Errorf("Переменная окружения должна переопределять файл: model = %s", cfg.LLM.Model)
+	}
+	if cfg.JWTSecret != "file-secret-0123456789" || cfg.LLM.Username != "user" || cfg.HTTPTimeout.Duration != 5*time.Second {
+		t.Errorf("Значения из файла не применились: %+v", cfg)
+	}
+	if cfg.SessionTimeout.Duration != 30*time.Minute {
+		t.Errorf("Незаданные значения должны браться по умолчанию: sessionTimeout = %s", cfg.SessionTimeout)
+	}
+
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	interpreter.ApplyConfig(cfg)
+	shown, err := interpreter.Execute("config show")
+	if err != nil {
+		t.Fatalf("config show вернул ошибку: %v", err)
+	}
+	text := shown.(string)
+	if strings.Contains(text, "file-secret") || strings.Contains(text, "file-password") || !strings.Contains(text, "env-model") {
+		t.Errorf("config show должен скрывать секреты:\n%s", text)
+	}
+	interpreter.SetRemote(true)
+	if _, err := interpreter.Execute("config show"); err == nil {
+		t.Errorf("config show не должен быть доступен веб-сессиям")
+	}
+
+	unsigned := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(unsigned, []byte(`{"llm": {"provider": "mock"}}`), 0644)
+	cfg, err = config.Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", unsigned})
+	if err != nil {
+		t.Fatalf("Конфигурация без секрета должна загружаться для терминала: %v", err)
+	}
+	if cfg.RequireJWTSecret() == nil {
+		t.Errorf("Без секрета JWT веб-сервер не должен запускаться")
+	}
+
+	invalid := [][]string{
+		{"-config", path, "-http-timeout", "0s"},
+		{"-config", path, "-jwt-secret", "short"},
+		{"-config", path, "-llm", "unknown"},
+		{"-config", path, "-llm", "openai"},
+		{"-config", filepath.Join(t.TempDir(), "missing.json")},
+	}
+	for _, args := range invalid {
+		if _, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), args); err == nil {
+			t.Errorf("Ожидалась ошибка валидации для %v", args)
+		}
+	}
+	fmt.Printf("✅ Конфигурация: флаги > env > файл > значения по умолчанию\n")
+}
+
+func TestLLMStreaming(t *testing.T) {
+	openAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Мос", "ква"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", delta)
+			w.(http.Flusher).Flush()
+		}
+		if r.URL.Query().Get("hang") != "" {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer openAIServer.Close()
+
+	ollamaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"content":"Мос"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"content":"ква"},"done":true}`)
+	}))
+	defer ollamaServer.Close()
+
+	request := business.ChatRequest{Messages: []business.ChatMessage{{Role: "user", Content: "столица России"}}, Stream: true}
+	for _, cfg := range []config.LLM{
+		{Provider: "openai", Endpoint: openAIServer.URL},
+		{Provider: "ollama", Endpoint: ollamaServer.URL},
+	} {
+		provider, _ := business.NewLLMProvider(cfg)
+		var deltas []string
+		response, err := provider.(business.StreamingLLMProvider).ChatStream(context.Background(), request, func(delta string) {
+			deltas = append(deltas, delta)
+		})
+		if err != nil || response.Content != "Москва" || len(deltas) != 2 {
+			t.Errorf("%s: получено %v %v (%v)", cfg.Provider, response, deltas, err)
+		}
+	}
+
+	provider, _ := business.NewLLMProvider(config.LLM{Provider: "openai", Endpoint: openAIServer.URL + "?hang=1"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := provider.(business.StreamingLLMProvider).ChatStream(ctx, request, func(delta string) {
+			cancel()
+		})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Прерванный поток должен возвращать ошибку")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Поток не прервался после отмены контекста")
+	}
+
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	sessions := presentation.NewSessionManager(func() *business.Interpreter {
+		interpreter := business.NewInterpreter(historyRepo)
+		interpreter.SetLLMProvider(business.NewMockProvider())
+		return interpreter
+	}, time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(presentation.NewWebHandler(sessions).StreamHandler))
+	defer server.Close()
+
+	if resp, err := http.Get(server.URL + "?command=" + url.QueryEscape("привет мир")); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("GET-запрос к потоку должен отклоняться, статус: %d", resp.StatusCode)
+		}
+	}
+	if resp, err := http.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader("command=x")); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("Запрос формы к потоку должен отклоняться, статус: %d", resp.StatusCode)
+		}
+	}
+
+	body, _ := json.Marshal(map[string]string{"command": "привет мир"})
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Запрос к потоку не удался: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type: %s", contentType)
+	}
+
+	var tokens []string
+	var final struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+	event := ""
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && event == "token":
+			var token struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &token)
+			tokens = append(tokens, token.Text)
+		case strings.HasPrefix(line, "data: ") && event == "result":
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &final)
+		}
+	}
+	if len(tokens) < 2 || strings.Join(tokens, "") != "[mock] привет мир" {
+		t.Errorf("Ожидались токены ответа, получено: %q", tokens)
+	}
+	if !final.Success || final.Message != "[mock] привет мир" {
+		t.Errorf("Итоговое событие: %+v", final)
+	}
+	fmt.Printf("✅ Ответы LLM передаются потоком (%d токенов)\n", len(tokens))
+}
+
+func TestConversationMemory(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+	mock := business.NewMockProvider()
+	interpreter.SetLLMProvider(mock)
+
+	if _, err := interpreter.Execute("x = 21"); err != nil {
+		t.Fatalf("Присваивание не удалось: %v", err)
+	}
+	if _, err := interpreter.Execute("сколько будет икс плюс один"); err != nil {
+		t.Fatalf("Первый вопрос не удался: %v", err)
+	}
+	if _, err := interpreter.Execute("а теперь умножь на два"); err != nil {
+		t.Fatalf("Второй вопрос не удался: %v", err)
+	}
+
+	requests := mock.Requests()
+	last := requests[len(requests)-1].Messages
+	var context []string
+	for _, message := range last {
+		context = append(context, message.Role+": "+message.Content)
+	}
+	joined := strings.Join(context, "\n")
+	for _, expected := range []string{"x = 21", "user: сколько будет икс плюс один", "assistant: [mock] сколько будет икс плюс один", "user: а теперь умножь на два"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Контекст второго вопроса не содержит %q:\n%s", expected, joined)
+		}
+	}
+
+	if _, err := interpreter.Execute("reset"); err != nil {
+		t.Fatalf("reset вернул ошибку: %v", err)
+	}
+	if interpreter.Conversation().Len() != 0 {
+		t.Errorf("После reset память должна быть пустой")
+	}
+
+	conversation := business.NewConversation(100)
+	for n := 0; n < 20; n++ {
+		conversation.Add(fmt.Sprintf("вопрос номер %d", n), strings.Repeat("длинный ответ ", 10))
+	}
+	if turns := conversation.Len(); turns == 0 || turns >= 20 {
+		t.Errorf("Память должна обрезаться по бюджету токенов, осталось реплик: %d", turns)
+	}
+	fmt.Printf("✅ Память разговора сохраняет контекст и укладывается в бюджет\n")
+}
+
+func TestResponseCache(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "llm_cache.json")
+	cache := storage.NewResponseCache(cachePath, time.Hour, 10, 0)
+
+	mock := business.NewMockProvider()
+	newInterpreter := func() *business.Interpreter {
+		interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+		interpreter.SetLLMProvider(mock)
+		interpreter.SetResponseCache(cache)
+		return interpreter
+	}
+	interpreter := newInterpreter()
+
+	ask := func(command string) string {
+		t.Helper()
+		result, err := newInterpreter().Execute(command)
+		if err != nil {
+			t.Fatalf("%q вернул ошибку: %v", command, err)
+		}
+		return fmt.Sprint(result)
+	}
+
+	first := ask("расскажи анекдот про кэш")
+	calls := len(mock.Requests())
+	if second := ask("расскажи анекдот про кэш"); second != first {
+		t.Errorf("Ответ из кэша отличается: %q != %q", second, first)
+	}
+	if len(mock.Requests()) != calls {
+		t.Errorf("Повторный вопрос не должен обращаться к LLM: было %d запросов, стало %d", calls, len(mock.Requests()))
+	}
+	if stats := cache.Stats(); stats.Hits == 0 || stats.Entries == 0 {
+		t.Errorf("Статистика кэша не учла попадание: %+v", stats)
+	}
+
+	ask("nocache расскажи анекдот про кэш")
+	if len(mock.Requests()) == calls {
+		t.Errorf("nocache должен обходить кэш")
+	}
+
+	stats, err := interpreter.Execute("cache stats")
+	if err != nil || !strings.Contains(fmt.Sprint(stats), "AI") {
+		t.Errorf("cache stats: %q, %v", stats, err)
+	}
+
+	if _, err := os.Stat(cachePath); err == nil {
+		t.Errorf("Кэш не должен записываться на диск при каждом ответе")
+	}
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("Не удалось сохранить кэш: %v", err)
+	}
+	reloaded := storage.NewResponseCache(cachePath, time.Hour, 10, 0)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Не удалось загрузить кэш с диска: %v", err)
+	}
+	if reloaded.Stats().Entries != cache.Stats().Entries {
+		t.Errorf("После загрузки с диска записей %d, ожидалось %d", reloaded.Stats().Entries, cache.Stats().Entries)
+	}
+
+	remote := newInterpreter()
+	remote.SetRemote(true)
+	for _, command := range []string{"cache clear", "cache stats"} {
+		if _, err := remote.Execute(command); err == nil {
+			t.Errorf("%s не должна быть доступна веб-сессиям", command)
+		}
+	}
+	if cache.Stats().Entries == 0 {
+		t.Errorf("Веб-сессия не должна очищать общий кэш")
+	}
+
+	if _, err := interpreter.Execute("cache clear"); err != nil {
+		t.Fatalf("cache clear вернул ошибку: %v", err)
+	}
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Errorf("После очистки в кэше осталось %d записей", entries)
+	}
+
+	limited := storage.NewResponseCache(filepath.Join(t.TempDir(), "limited.json"), time.Hour, 2, 0)
+	for _, key := range []string{"a", "b", "c"} {
+		limited.Put(key, "значение "+key)
+	}
+	if _, ok := limited.Get("a"); ok {
+		t.Errorf("Самая старая запись должна быть вытеснена")
+	}
+	if stats := limited.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("Ожидалось 2 записи и 1 вытеснение: %+v", stats)
+	}
+
+	expiring := storage.NewResponseCache(filepath.Join(t.TempDir(), "expiring.json"), 20*time.Millisecond, 0, 0)
+	expiring.Put("ключ", "значение")
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := expiring.Get("ключ"); ok {
+		t.Errorf("Запись с истёкшим TTL не должна возвращаться")
+	}
+	fmt.Printf("✅ Кэш ответов AI работает\n")
+}
+
+func TestLLMRetryAndCircuitBreaker(t *testing.T) {
+	var mu sync.Mutex
+	hits := 0
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		fail := failures != 0
+		if failures > 0 {
+			failures--
+		}
+		mu.Unlock()
+
+		if fail {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "proxy overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "ответ после повтора"}}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := config.LLM{
+		Provider:           "openai",
+		Endpoint:           server.URL,
+		MaxRetries:         2,
+		RetryDelay:         config.Duration{Duration: time.Millisecond},
+		RetryMaxDelay:      config.Duration{Duration: 10 * time.Millisecond},
+		BreakerThreshold:   0.5,
+		BreakerMinRequests: 2,
+		BreakerCooldown:    config.Duration{Duration: time.Hour},
+	}
+	provider, err := business.NewLLMProvider(cfg)
+	if err != nil {
+		t.Fatalf("Не удалось создать провайдера: %v", err)
+	}
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	interpreter.SetLLMProvider(provider)
+
+	result, err := interpreter.SendTextToDeepSeek(context.Background(), "вопрос")
+	if err != nil || !strings.Contains(result, "ответ после повтора") {
+		t.Fatalf("Запрос должен пройти после повторов: %q, %v", result, err)
+	}
+	if hits != 3 {
+		t.Errorf("Ожидалось 3 обращения к серверу (2 ошибки и успех), было %d", hits)
+	}
+
+	mu.Lock()
+	failures = -1
+	hits = 0
+	mu.Unlock()
+	if _, err := interpreter.SendTextToDeepSeek(context.Background(), "вопрос"); err == nil {
+		t.Fatalf("Ожидалась ошибка от недоступного сервера")
+	}
+	if hits != 3 {
+		t.Errorf("Запрос должен повторяться: ожидалось 3 обращения, было %d", hits)
+	}
+
+	_, err = interpreter.SendTextToDeepSeek(context.Background(), "вопрос")
+	if err == nil || !strings.Contains(err.Error(), "недоступен") {
+		t.Errorf("После порога ошибок AI должен считаться недоступным, получено: %v", err)
+	}
+	if hits != 3 {
+		t.Errorf("Открытый предохранитель не должен обращаться к серверу, обращений: %d", hits)
+	}
+
+	drops := 0
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		drops++
+		drop := drops <= 2
+		mu.Unlock()
+		if drop {
+			panic(http.ErrAbortHandler)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "ответ после обрыва"}}},
+		})
+	}))
+	defer flaky.Close()
+	cfg.Endpoint = flaky.URL
+	provider, _ = business.NewLLMProvider(cfg)
+	interpreter.SetLLMProvider(provider)
+	result, err = interpreter.SendTextToDeepSeek(context.Background(), "вопрос")
+	if err != nil || !strings.Contains(result, "ответ после обрыва") || drops != 3 {
+		t.Errorf("Обрыв соединения должен повторяться: %q, %v, обращений %d", result, err, drops)
+	}
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+	cfg.Endpoint = unauthorized.URL
+	provider, _ = business.NewLLMProvider(cfg)
+	interpreter.SetLLMProvider(provider)
+	interpreter.SendTextToDeepSeek(context.Background(), "вопрос")
+	interpreter.SendTextToDeepSeek(context.Background(), "вопрос")
+	if _, err := interpreter.SendTextToDeepSeek(context.Background(), "вопрос"); err == nil || !strings.Contains(err.Error(), "недоступен") {
+		t.Errorf("Ответы 401 должны считаться ошибками предохранителя, получено: %v", err)
+	}
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"нач\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer broken.Close()
+	streamCfg := cfg
+	streamCfg.Endpoint = broken.URL
+	streamCfg.BreakerMinRequests = 1
+	streamProvider, _ := business.NewLLMProvider(streamCfg)
+	streaming := streamProvider.(business.StreamingLLMProvider)
+	request := business.ChatRequest{Messages: []business.ChatMessage{{Role: "user", Content: "вопрос"}}}
+	if _, err := streaming.ChatStream(context.Background(), request, func(string) {}); err == nil {
+		t.Errorf("Ожидалась ошибка при обрыве потока")
+	}
+	_, err = streaming.ChatStream(context.Background(), request, func(string) {})
+	if llmErr, ok := err.(*business.LLMError); !ok || llmErr.Kind != business.LLMErrorUnavailable {
+		t.Errorf("Обрыв потока должен учитываться предохранителем, получено: %v", err)
+	}
+
+	probeHits := 0
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		probeHits++
+		first := probeHits == 1
+		mu.Unlock()
+		if first {
+			http.Error(w, "proxy overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		<-unblock
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "ответ"}}},
+		})
+	}))
+	defer slow.Close()
+	defer close(unblock)
+	probeCfg := cfg
+	probeCfg.Endpoint = slow.URL
+	probeCfg.MaxRetries = 0
+	probeCfg.BreakerMinRequests = 1
+	probeCfg.BreakerCooldown = config.Duration{Duration: 20 * time.Millisecond}
+	probeProvider, _ := business.NewLLMProvider(probeCfg)
+	probeProvider.Chat(context.Background(), request)
+	time.Sleep(30 * time.Millisecond)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := probeProvider.Chat(cancelled, request); err == nil {
+		t.Errorf("Отменённый запрос должен возвращать ошибку")
+	}
+	go probeProvider.Chat(context.Background(), request)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		started := probeHits == 2
+		mu.Unlock()
+		if started {
+			break
+		}
+	}
+	ctx, stop := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer stop()
+	_, err = probeProvider.Chat(ctx, request)
+	if llmErr, ok := err.(*business.LLMError); !ok || llmErr.Kind != business.LLMErrorUnavailable {
+		t.Errorf("Отменённый пробный запрос не должен закрывать предохранитель, получено: %v", err)
+	}
+
+	breaker := business.NewCircuitBreaker(0.5, 1, 20*time.Millisecond)
+	breaker.Record(false)
+	if allowed, _ := breaker.Allow(); allowed {
+		t.Errorf("Открытый предохранитель не должен пропускать запросы")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if allowed, _ := breaker.Allow(); !allowed {
+		t.Errorf("После паузы предохранитель должен пропустить пробный запрос")
+	}
+	if allowed, _ := breaker.Allow(); allowed {
+		t.Errorf("Пока идёт пробный запрос, остальные должны отклоняться")
+	}
+	breaker.Record(false)
+	if allowed, _ := breaker.Allow(); allowed {
+		t.Errorf("Неудачный пробный запрос должен снова открыть предохранитель")
+	}
+	time.Sleep(30 * time.Millisecond)
+	breaker.Allow()
+	breaker.Release()
+	if allowed, _ := breaker.Allow(); !allowed {
+		t.Errorf("Освобождённый пробный запрос должен уступить место следующему")
+	}
+	if allowed, _ := breaker.Allow(); allowed {
+		t.Errorf("Освобождённый пробный запрос не должен закрывать предохранитель")
+	}
+	breaker.Record(true)
+	for n := 0; n < 2; n++ {
+		if allowed, _ := breaker.Allow(); !allowed {
+			t.Errorf("Удачный пробный запрос должен закрыть предохранитель")
+		}
+	}
+	fmt.Printf("✅ Повторы запросов к AI и предохранитель работают\n")
+}
+
+func TestTokenUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "ответ"}}},
+			"usage":   map[string]int{"prompt_tokens": 100, "completion_tokens": 20},
+		})
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Usage.DailyTokens = 200
+	cfg.Usage.PromptPrice = 1
+	cfg.LLM.MaxToolRounds = 0
+	provider, _ := business.NewLLMProvider(config.LLM{Provider: "openai", Endpoint: server.URL})
+
+	usagePath := filepath.Join(t.TempDir(), "usage.json")
+	store := storage.NewUsageStore(usagePath)
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	interpreter.ApplyConfig(cfg)
+	interpreter.SetLLMProvider(provider)
+	interpreter.SetUsageStore(store)
+	interpreter.SetUser("alice")
+
+	for n := 0; n < 2; n++ {
+		if _, err := interpreter.SendTextToDeepSeek(context.Background(), "вопрос"); err != nil {
+			t.Fatalf("Запрос %d не удался: %v", n+1, err)
+		}
+	}
+	report := interpreter.Usage()
+	if report.Session.Total() != 240 || report.Today.Total() != 240 || report.Session.Requests != 2 {
+		t.Errorf("Ожидалось 240 токенов за 2 запроса: %+v", report)
+	}
+	if math.Abs(report.SessionCost-0.2) > 1e-9 {
+		t.Errorf("Стоимость 200 токенов запроса по цене 1 за 1000 должна быть 0.2, получено %v", report.SessionCost)
+	}
+
+	_, err := interpreter.SendTextToDeepSeek(context.Background(), "вопрос")
+	if err == nil || !strings.Contains(err.Error(), "лимит") {
+		t.Errorf("После исчерпания лимита ожидалась ошибка, получено: %v", err)
+	}
+
+	text, err := interpreter.Execute("usage")
+	if err != nil || !strings.Contains(fmt.Sprint(text), "alice") {
+		t.Errorf("usage: %q, %v", text, err)
+	}
+
+	reloaded := storage.NewUsageStore(usagePath)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Не удалось загрузить учёт расхода: %v", err)
+	}
+	if total := reloaded.Today("alice").Total(); total != 240 {
+		t.Errorf("После загрузки с диска у alice %d токенов, ожидалось 240", total)
+	}
+
+	sessions := presentation.NewSessionManager(func() *business.Interpreter { return interpreter }, time.Minute)
+	handler := presentation.NewWebHandler(sessions)
+	recorder := httptest.NewRecorder()
+	handler.UsageHandler(recorder, httptest.NewRequest(http.MethodGet, "/api/usage", nil))
+	var body business.UsageReport
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body.User != "alice" || body.Today.Total() != 240 {
+		t.Errorf("GET /api/usage вернул %+v, %v", body, err)
+	}
+	fmt.Printf("✅ Расход токенов AI учитывается и ограничивается\n")
+}
+
+func TestWebUsageIdentity(t *testing.T) {
+	previousKey := jwtKey
+	jwtKey = []byte("test-secret-0123456789")
+	t.Cleanup(func() { jwtKey = previousKey })
+
+	cfg := config.Default()
+	cfg.Usage.DailyTokens = 1000
+	cfg.Usage.Admins = []string{"root"}
+	cfg.LLM.MaxToolRounds = 0
+	store := storage.NewUsageStore(filepath.Join(t.TempDir(), "usage.json"))
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	sessions := presentation.NewSessionManager(func() *business.Interpreter {
+		interpreter := business.NewInterpreter(historyRepo)
+		interpreter.ApplyConfig(cfg)
+		interpreter.SetLLMProvider(business.NewMockProvider())
+		interpreter.SetUsageStore(store)
+		return interpreter
+	}, time.Minute)
+	handler := presentation.NewWebHandler(sessions)
+	handler.SetIdentify(usernameFromToken)
+	s := NewServer()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/calculate", handler.CalculateHandler)
+	mux.HandleFunc("/api/usage", handler.UsageHandler)
+	mux.HandleFunc("/api/auth/login", s.loginHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	newClient := func() *http.Client {
+		jar, _ := cookiejar.New(nil)
+		return &http.Client{Jar: jar}
+	}
+	calculate := func(client *http.Client, command, token string) string {
+		body, _ := json.Marshal(map[string]string{"command": command})
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/calculate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Запрос %s не удался: %v", command, err)
+		}
+		defer resp.Body.Close()
+		var result struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result.Message
+	}
+	login := func(client *http.Client, username string) string {
+		body, _ := json.Marshal(map[string]string{"username": username})
+		resp, err := client.Post(server.URL+"/api/auth/login", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Вход %s не удался: %v", username, err)
+		}
+		defer resp.Body.Close()
+		var result map[string]string
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result["token"]
+	}
+	usage := func(client *http.Client, token string) business.UsageReport {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/usage", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Запрос расхода не удался: %v", err)
+		}
+		defer resp.Body.Close()
+		var report business.UsageReport
+		json.NewDecoder(resp.Body).Decode(&report)
+		return report
+	}
+
+	anonymous := newClient()
+	if got := calculate(anonymous, "столица России", ""); !strings.Contains(got, "войдите") {
+		t.Errorf("Анонимная сессия не должна расходовать общий лимит AI: %s", got)
+	}
+	if got := calculate(anonymous, "2 + 2", ""); got != "4" {
+		t.Errorf("Вычисления должны работать без входа: %s", got)
+	}
+
+	alice := newClient()
+	aliceToken := login(alice, "alice")
+	if got := calculate(alice, "столица России", ""); !strings.Contains(got, "войдите") {
+		t.Errorf("Без токена в запросе расход не должен списываться на вошедшего пользователя: %s", got)
+	}
+	if got := calculate(alice, "столица России", aliceToken); !strings.Contains(got, "[mock]") {
+		t.Errorf("Запрос с токеном должен выполняться: %s", got)
+	}
+	if report := usage(alice, aliceToken); report.User != "alice" {
+		t.Errorf("Расход должен учитываться для alice, получено %q", report.User)
+	}
+
+	bobToken := login(newClient(), "bob")
+	calculate(alice, "столица России", bobToken)
+	if got := calculate(alice, "столица России", ""); !strings.Contains(got, "войдите") {
+		t.Errorf("Токен одного запроса не должен оставаться в сессии: %s", got)
+	}
+	if store.Today("alice").Total() == 0 || store.Today("bob").Total() == 0 || store.Today("anonymous").Total() != 0 {
+		t.Errorf("Расход должен учитываться по пользователям: %+v", store.TodayByUser())
+	}
+
+	if report := usage(newClient(), ""); len(report.Users) > 1 || report.Users["alice"].Total() != 0 {
+		t.Errorf("Анонимный запрос не должен видеть расход других пользователей: %+v", report.Users)
+	}
+	if report := usage(newClient(), bobToken); len(report.Users) != 1 || report.Users["bob"].Total() == 0 {
+		t.Errorf("Пользователь должен видеть только свой расход: %+v", report.Users)
+	}
+	if report := usage(newClient(), login(newClient(), "root")); len(report.Users) != 2 {
+		t.Errorf("Администратор должен видеть расход всех пользователей: %+v", report.Users)
+	}
+}
+
+func TestPromptTemplates(t *testing.T) {
+	dir := t.TempDir()
+	answerPath := filepath.Join(dir, "answer.tmpl")
+	writePrompt := func(text string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(answerPath, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(answerPath, modTime, modTime)
+	}
+	writePrompt("{{/* version: 7 */ -}}\nОтвечай кратко, {{.User}} ({{.Locale}}). {{.State}}", time.Now().Add(-time.Hour))
+
+	prompts, err := business.LoadPromptLibrary(dir)
+	if err != nil {
+		t.Fatalf("Не удалось загрузить шаблоны: %v", err)
+	}
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	mock := business.NewMockProvider()
+	interpreter.SetLLMProvider(mock)
+	interpreter.SetPrompts(prompts)
+	interpreter.SetUser("alice")
+	interpreter.Execute("x = 5")
+
+	systemPrompt := func() string {
+		t.Helper()
+		if _, err := interpreter.SendTextToDeepSeek(context.Background(), "вопрос"); err != nil {
+			t.Fatalf("Запрос не удался: %v", err)
+		}
+		requests := mock.Requests()
+		return requests[len(requests)-1].Messages[0].Content
+	}
+
+	if got := systemPrompt(); got != "Отвечай кратко, alice (ru). x = 5\n_1 = 5\nans = _1" {
+		t.Errorf("Неожиданный системный промпт: %q", got)
+	}
+
+	writePrompt("{{/* version: 8 */ -}}\nAnswer briefly.", time.Now())
+	if got := systemPrompt(); got != "Answer briefly." {
+		t.Errorf("Изменённый шаблон должен подхватываться без перезапуска, получено %q", got)
+	}
+
+	info, err := interpreter.Execute("prompts")
+	if err != nil || !strings.Contains(fmt.Sprint(info), "answer — версия 8") || !strings.Contains(fmt.Sprint(info), "classify — версия 1") {
+		t.Errorf("prompts: %v, %v", info, err)
+	}
+
+	writePrompt("{{.Broken", time.Now().Add(time.Minute))
+	if got := systemPrompt(); got != "Answer briefly." {
+		t.Errorf("При ошибке в шаблоне должна использоваться предыдущая версия, получено %q", got)
+	}
+	if _, err := interpreter.Execute("prompts reload"); err == nil {
+		t.Errorf("prompts reload должен сообщить об ошибке в шаблоне")
+	}
+	fmt.Printf("✅ Шаблоны промптов загружаются из файлов и перечитываются\n")
+}
+
+func TestLLMToolCalling(t *testing.T) {
+	var mu sync.Mutex
+	var requests []map[string]interface{}
+	alwaysCallTools := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requests = append(requests, req)
+		round := len(requests)
+		mu.Unlock()
+
+		messages := req["messages"].([]interface{})
+		last := messages[len(messages)-1].(map[string]interface{})
+		if _, hasTools := req["tools"]; hasTools && (round == 1 || alwaysCallTools) {
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"evaluate","arguments":"{\"expression\":\"(17+4)*2\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"convert_units","arguments":"{\"value\":5,\"from\":\"km\",\"to\":\"m\"}"}},
+				{"id":"call_3","type":"function","function":{"name":"set_variable","arguments":"{\"name\":\"total\",\"expression\":\"40+2\"}"}}
+			]}}]}`)
+			return
+		}
+		if last["role"] != "tool" {
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"без инструментов"}}]}`)
+			return
+		}
+		var toolResults []string
+		for _, message := range messages {
+			if m := message.(map[string]interface{}); m["role"] == "tool" {
+				toolResults = append(toolResults, m["content"].(string))
+			}
+		}
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%q}}]}`, "итог: "+strings.Join(toolResults, ", "))
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.LLM.MaxToolRounds = 3
+	provider, _ := business.NewLLMProvider(config.LLM{Provider: "openai", Endpoint: server.URL})
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	interpreter.ApplyConfig(cfg)
+	interpreter.SetLLMProvider(provider)
+
+	answer, err := interpreter.SendTextToDeepSeek(context.Background(), "сколько будет (17+4)*2")
+	if err != nil {
+		t.Fatalf("Запрос с инструментами не удался: %v", err)
+	}
+	if answer != "итог: 42, 5000, 42" {
+		t.Errorf("Ответ должен строиться на результатах инструментов, получено: %q", answer)
+	}
+	if total, err := interpreter.Execute("total"); err != nil || total != 42.0 {
+		t.Errorf("set_variable должен сохранять переменную: %v (%v)", total, err)
+	}
+
+	mu.Lock()
+	requests = nil
+	alwaysCallTools = true
+	mu.Unlock()
+	if _, err := interpreter.SendTextToDeepSeek(context.Background(), "зациклись"); err != nil {
+		t.Fatalf("Запрос с лимитом раундов не удался: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != cfg.LLM.MaxToolRounds+1 {
+		t.Errorf("Ожидалось %d запросов, получено %d", cfg.LLM.MaxToolRounds+1, len(requests))
+	}
+	if _, hasTools := requests[len(requests)-1]["tools"]; hasTools {
+		t.Errorf("Последний запрос после лимита раундов не должен предлагать инструменты")
+	}
+
+	conversions := []struct {
+		value    float64
+		from, to string
+		expected float64
+	}{
+		{100, "c", "f", 212},
+		{1, "mi", "km", 1.609344},
+		{2, "кг", "г", 2000},
+		{90, "min", "h", 1.5},
+	}
+	for _, c := range conversions {
+		if got, err := business.ConvertUnits(c.value, c.from, c.to); err != nil || math.Abs(got-c.expected) > 1e-9 {
+			t.Errorf("%v %s → %s: получено %v (%v), ожидалось %v", c.value, c.from, c.to, got, err, c.expected)
+		}
+	}
+	if _, err := business.ConvertUnits(1, "kg", "m"); err == nil {
+		t.Errorf("Перевод между разными величинами должен возвращать ошибку")
+	}
+	fmt.Printf("✅ AI вычисляет через инструменты калькулятора\n")
+}
+
+func TestNaturalLanguageMath(t *testing.T) {
+	expression := "3000*20/100"
+	mock := business.NewMockProvider()
+	mock.Reply = func(req business.ChatRequest) string {
+		if req.Purpose == business.LLMPurposeTranslate {
+			return fmt.Sprintf("```json\n{\"expression\": %q}\n```", expression)
+		}
+		return "примерно шестьсот"
+	}
+
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	interpreter.SetLLMProvider(mock)
+
+	result, err := interpreter.ExecuteResult(context.Background(), "сколько будет двадцать процентов от трёх тысяч")
+	if err != nil {
+		t.Fatalf("Перевод выражения не удался: %v", err)
+	}
+	if result.Route != business.RouteNLMath || result.Value != 600.0 || result.Expression != expression {
+		t.Errorf("Ожидалось проверенное выражение, получено: %+v", result)
+	}
+	if result.Text != "3000*20/100 = 600" || result.Ref == "" {
+		t.Errorf("Текст должен показывать выражение и результат: %q (ref %q)", result.Text, result.Ref)
+	}
+	if next, err := interpreter.Execute(result.Ref + " + 1"); err != nil || next != 601.0 {
+		t.Errorf("Результат должен быть доступен по ссылке: %v (%v)", next, err)
+	}
+
+	expression = "двадцать процентов"
+	result, err = interpreter.ExecuteResult(context.Background(), "посчитай двадцать процентов от трёх тысяч")
+	if err != nil {
+		t.Fatalf("Запасной ответ не удался: %v", err)
+	}
+	if result.Route != business.RouteLLM || result.Text != "примерно шестьсот" {
+		t.Errorf("Ожидался запасной ответ AI, получено: %+v", result)
+	}
+	if len(result.Warnings) == 0 || !strings.Contains(result.Warnings[0], "не проверен") {
+		t.Errorf("Запасной ответ должен быть помечен как непроверенный: %v", result.Warnings)
+	}
+	fmt.Printf("✅ Задачи на естественном языке проверяются калькулятором\n")
+}
+
+func TestMapReduceSummary(t *testing.T) {
+	var paragraphs []string
+	for idx := 1; idx <= 10; idx++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("<p>Абзац %d: %s</p>", idx, strings.Repeat("слово ", 15)))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Длинная статья</title></head><body>%s</body></html>", strings.Join(paragraphs, "\n"))
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Website.ChunkSize = 250
+	cfg.Website.MaxChunks = 4
+	cfg.Website.Workers = 2
+	cfg.Outbound.AllowPrivate = true
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	interpreter.ApplyConfig(cfg)
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	mock := business.NewMockProvider()
+	mock.Reply = func(req business.ChatRequest) string {
+		if req.Purpose != business.LLMPurposeSummarize {
+			return "итоговый пересказ"
+		}
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		prompt := req.Messages[len(req.Messages)-1].Content
+		for idx := 1; idx <= 10; idx++ {
+			if strings.Contains(prompt, fmt.Sprintf("Абзац %d:", idx)) {
+				return fmt.Sprintf("пересказ с абзаца %d", idx)
+			}
+		}
+		return "пустая часть"
+	}
+	interpreter.SetLLMProvider(mock)
+
+	var progress []business.Progress
+	ctx := business.WithProgress(context.Background(), func(p business.Progress) {
+		progress = append(progress, p)
+	})
+	result, err := interpreter.ExecuteResult(ctx, "проанализируй "+server.URL)
+	if err != nil {
+		t.Fatalf("Анализ длинной страницы не удался: %v", err)
+	}
+	if !strings.Contains(result.Text, "итоговый пересказ") || !strings.Contains(result.Text, "4 из 5") {
+		t.Errorf("В ответе нет итога или пометки о лимите частей: %s", result.Text)
+	}
+
+	var chunkRequests int
+	var final string
+	for _, req := range mock.Requests() {
+		switch req.Purpose {
+		case business.LLMPurposeSummarize:
+			chunkRequests++
+		case business.LLMPurposeAnalyze:
+			final = req.Messages[1].Content
+		}
+	}
+	if chunkRequests != 4 {
+		t.Errorf("Ожидалось 4 запроса на пересказ частей, получено %d", chunkRequests)
+	}
+	if maxActive > 2 {
+		t.Errorf("Одновременно выполнялось %d запросов при лимите 2", maxActive)
+	}
+	for _, expected := range []string{"Часть 1:\nпересказ с абзаца 1", "Часть 4:\nпересказ с абзаца 7"} {
+		if !strings.Contains(final, expected) {
+			t.Errorf("В итоговом запросе нет %q:\n%s", expected, final)
+		}
+	}
+	if strings.Contains(final, "Абзац 9") {
+		t.Errorf("Части сверх лимита не должны попадать в запрос:\n%s", final)
+	}
+
+	if len(progress) != 6 || progress[0].Done != 0 || progress[4].Done != 4 || progress[5].Stage != business.ProgressReduce {
+		t.Errorf("Неверная последовательность прогресса: %+v", progress)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(strings.Repeat("Короткая заметка. ", 5)), 0644)
+	interpreter.AddSafeDirectory(dir)
+	result, err = interpreter.ExecuteResult(context.Background(), "перескажи notes.txt")
+	if err != nil {
+		t.Fatalf("Пересказ файла не удался: %v", err)
+	}
+	if result.Route != business.RouteSummarize || !strings.Contains(result.Text, "notes.txt") {
+		t.Errorf("Ожидался пересказ файла, получено %s: %s", result.Route, result.Text)
+	}
+
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"jwtSecret": "секрет-из-конфига"}`), 0644)
+	remote := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	remoteMock := business.NewMockProvider()
+	remote.SetLLMProvider(remoteMock)
+	remote.AddSafeDirectory(dir)
+	remote.SetRemote(true)
+	for _, command := range []string{"перескажи config.json", "перескажи notes.txt"} {
+		if _, err := remote.Execute(command); err == nil {
+			t.Errorf("%s не должна быть доступна веб-сессиям", command)
+		}
+	}
+	for _, req := range remoteMock.Requests() {
+		for _, message := range req.Messages {
+			if strings.Contains(message.Content, "секрет-из-конфига") {
+				t.Errorf("Содержимое файла ушло в запрос к LLM из веб-сессии")
+			}
+		}
+	}
+}
+
+func TestOutboundPolicy(t *testing.T) {
+	var hits int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, strings.Replace("http://"+r.Host, "127.0.0.1", "localhost", 1)+"/final", http.StatusFound)
+		case "/big":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write(bytes.Repeat([]byte("x"), 1000))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		default:
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	interpreter := business.NewInterpreter(storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt")))
+	blocked := []struct {
+		command string
+		reason  string
+	}{
+		{"curl " + server.URL, "127.0.0.1"},
+		{"curl http://localhost:" + port, "внутренней сети"},
+		{"curl http://169.254.169.254/latest/meta-data/", "169.254.169.254"},
+		{"curl http://[::1]:" + port, "::1"},
+		{"curl file:///etc/passwd", "file"},
+	}
+	for _, test := range blocked {
+		_, err := interpreter.Execute(test.command)
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%s: ожидалась блокировка (%s), получено %v", test.command, test.reason, err)
+		}
+	}
+	interpreter.SetLLMProvider(business.NewMockProvider())
+	result, err := interpreter.ExecuteResult(context.Background(), "проанализируй "+server.URL)
+	if err != nil || !strings.Contains(strings.Join(result.Warnings, "\n"), "127.0.0.1") {
+		t.Errorf("Анализ внутреннего сайта должен блокироваться: %v, %v", result, err)
+	}
+	if hits != 0 {
+		t.Errorf("Заблокированные запросы не должны доходить до сервера, получено %d", hits)
+	}
+
+	cfg := config.Default()
+	cfg.Outbound.AllowNetworks = []string{"127.0.0.0/8"}
+	cfg.Outbound.DenyHosts = []string{"localhost"}
+	cfg.Outbound.MaxResponseBytes = 100
+	interpreter.ApplyConfig(cfg)
+
+	if result, err := interpreter.Execute("curl -s " + server.URL); err != nil || !strings.Contains(fmt.Sprint(result), `"ok"`) {
+		t.Errorf("Разрешённая сеть должна быть доступна: %v, %v", result, err)
+	}
+	for command, reason := range map[string]string{
+		"curl -L " + server.URL + "/redirect": "localhost",
+		"curl " + server.URL + "/big":         "100",
+		"curl " + server.URL + "/image":       "image/png",
+	} {
+		if _, err := interpreter.Execute(command); err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("%s: ожидалась ошибка с %q, получено %v", command, reason, err)
+		}
+	}
+
+	cfg.Outbound.AllowHosts = []string{"example.org"}
+	interpreter.ApplyConfig(cfg)
+	if _, err := interpreter.Execute("curl " + server.URL); err == nil || !strings.Contains(err.Error(), "127.0.0.1") {
+		t.Errorf("Хост вне списка разрешённых должен блокироваться, получено %v", err)
+	}
+}
@@ -1,54 +1,189 @@
 package presentation
 
 import (
-	"bufio"
 	"calculator/business"
+	"calculator/storage"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"unicode"
 )
 
+const cliHistorySize = 500
+
 type CLI struct {
 	interpreter *business.Interpreter
+	historyRepo *storage.HistoryRepository
 }
 
-func NewCLI(interpreter *business.Interpreter) *CLI {
-	return &CLI{interpreter: interpreter}
+func NewCLI(interpreter *business.Interpreter, historyRepo *storage.HistoryRepository) *CLI {
+	return &CLI{interpreter: interpreter, historyRepo: historyRepo}
 }
 
 func (c *CLI) Run() {
-	scanner := bufio.NewScanner(os.Stdin)
+	editor := newLineEditor(os.Stdin, os.Stdout, c.historyRepo.GetLastCommands(cliHistorySize), c.complete)
 
 	for {
-		fmt.Print("> ")
-		if !scanner.Scan() {
+		lines, err := c.readInput(editor)
+		if err == errInterrupted {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("%s: %v\n", c.msg("ui.input_error"), err)
+			}
+			fmt.Println(c.msg("ui.goodbye"))
 			break
 		}
 
-		input := strings.TrimSpace(scanner.Text())
-		if input == "" {
-			continue
+		for _, input := range lines {
+			if input == "exit" {
+				fmt.Println(c.msg("ui.goodbye"))
+				return
+			}
+			c.execute(input)
 		}
+	}
+}
 
-		if input == "exit" {
-			fmt.Println("До свидания!")
-			break
+func (c *CLI) readInput(editor *lineEditor) ([]string, error) {
+	var lines []string
+	prompt := "> "
+
+	for {
+		line, err := editor.ReadLine(prompt)
+		if err != nil {
+			return nil, err
 		}
 
-		if input == "history" {
-			history := c.interpreter.GetHistory()
-			fmt.Println("Последние 10 команд:")
-			for i, cmd := range history {
-				fmt.Printf("%d: %s\n", i+1, cmd)
+		continued := strings.HasSuffix(line, "\\")
+		line = strings.TrimSuffix(line, "\\")
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			editor.AddHistory(trimmed)
+			lines = append(lines, trimmed)
+		}
+
+		if !continued {
+			return lines, nil
+		}
+		prompt = "... "
+	}
+}
+
+func (c *CLI) execute(input string) {
+	if input == "history" {
+		history := c.interpreter.GetHistoryEntries()
+		fmt.Println(c.msg("ui.history_title"))
+		for i, entry := range history {
+			if entry.Result != "" {
+				fmt.Printf("%d: %s → %s\n", i+1, entry.Command, entry.Result)
+			} else {
+				fmt.Printf("%d: %s\n", i+1, entry.Command)
 			}
-			continue
 		}
+		return
+	}
 
-		result, err := c.interpreter.Execute(input)
-		if err != nil {
-			fmt.Printf("Ошибка: %v\n", err)
-		} else {
-			fmt.Printf("Результат: %v\n", result)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	streamed := false
+	ctx = business.WithTokenStream(ctx, func(delta string) {
+		if !streamed {
+			fmt.Printf("%s:\n", c.msg("ui.ai_answer"))
+			streamed = true
+		}
+		fmt.Print(delta)
+	})
+	ctx = business.WithProgress(ctx, func(progress business.Progress) {
+		if progress.Stage == business.ProgressReduce {
+			fmt.Println(c.msg("ui.progress_reduce"))
+			return
+		}
+		fmt.Printf(c.msg("ui.progress")+"\n", progress.Done, progress.Total)
+	})
+
+	result, err := c.interpreter.ExecuteResult(ctx, input)
+	if streamed {
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("%s: %v\n", c.msg("ui.error"), err)
+		return
+	}
+	if streamed && result.Kind == business.ResultLLMAnswer {
+		for _, warning := range result.Warnings {
+			fmt.Printf("%s: %s\n", c.msg("ui.warning"), warning)
+		}
+		return
+	}
+	c.printResult(result)
+}
+
+func (c *CLI) msg(key string) string {
+	return business.Message(c.interpreter.Locale(), key)
+}
+
+func (c *CLI) printResult(result *business.Result) {
+	for _, warning := range result.Warnings {
+		fmt.Printf("%s: %s\n", c.msg("ui.warning"), warning)
+	}
+
+	switch result.Kind {
+	case business.ResultNumber, business.ResultBool:
+		fmt.Printf("%s [%s]: %s\n", c.msg("ui.result"), result.Ref, result.Text)
+	case business.ResultList:
+		for i, item := range result.Value.([]string) {
+			fmt.Printf("%d: %s\n", i+1, item)
+		}
+	case business.ResultLLMAnswer:
+		fmt.Printf("%s:\n%s\n", c.msg("ui.ai_answer"), result.Text)
+	default:
+		fmt.Println(result.Text)
+	}
+}
+
+func (c *CLI) complete(line string) (int, []string) {
+	var candidates []string
+
+	keywords := append(c.interpreter.CommandKeywords(), "exit")
+	if strings.TrimSpace(line) != "" {
+		for _, keyword := range keywords {
+			if strings.HasPrefix(keyword, line) && keyword != line {
+				candidates = append(candidates, keyword)
+			}
+		}
+		if len(candidates) > 0 {
+			return 0, candidates
+		}
+	}
+
+	start := strings.LastIndexFunc(line, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if start == -1 {
+		start = 0
+	} else {
+		start += len(string([]rune(line[start:])[0]))
+	}
+
+	word := line[start:]
+	if word == "" {
+		return start, nil
+	}
+
+	for _, name := range c.interpreter.FunctionNames() {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, name)
+		}
+	}
+	for _, name := range c.interpreter.VariableNames() {
+		if strings.HasPrefix(name, word) && name != word {
+			candidates = append(candidates, name)
 		}
 	}
+	return start, candidates
 }
@@ -0,0 +1,421 @@
+package presentation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var errInterrupted = errors.New("ввод прерван")
+
+const escapeTimeout = 100 * time.Millisecond
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlJ     = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+const (
+	keyUp = -(iota + 1)
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDeleteForward
+	keyUnknown
+)
+
+type completer func(line string) (start int, candidates []string)
+
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	history  []string
+	complete completer
+}
+
+func newLineEditor(in *os.File, out io.Writer, history []string, complete completer) *lineEditor {
+	return &lineEditor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		fd:       int(in.Fd()),
+		history:  history,
+		complete: complete,
+	}
+}
+
+func (e *lineEditor) AddHistory(line string) {
+	if line == "" {
+		return
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	if !isTerminal(e.fd) {
+		return e.readPlainLine(prompt)
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+	defer restore()
+
+	return e.readRawLine(prompt)
+}
+
+func (e *lineEditor) readPlainLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (e *lineEditor) readRawLine(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	historyIdx := len(e.history)
+	saved := ""
+	lastWasTab := false
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(line string) {
+		buf = []rune(line)
+		pos = len(buf)
+		refresh()
+	}
+
+	refresh()
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		if key != keyTab {
+			lastWasTab = false
+		}
+
+		switch key {
+		case keyEnter, keyCtrlJ:
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+				refresh()
+			}
+		case keyDeleteForward:
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+				refresh()
+			}
+		case keyBackspace, keyDelete:
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+				refresh()
+			}
+		case keyLeft, keyCtrlB:
+			if pos > 0 {
+				pos--
+				refresh()
+			}
+		case keyRight, keyCtrlF:
+			if pos < len(buf) {
+				pos++
+				refresh()
+			}
+		case keyHome, keyCtrlA:
+			pos = 0
+			refresh()
+		case keyEnd, keyCtrlE:
+			pos = len(buf)
+			refresh()
+		case keyCtrlK:
+			buf = buf[:pos]
+			refresh()
+		case keyCtrlU:
+			buf = buf[pos:]
+			pos = 0
+			refresh()
+		case keyCtrlW:
+			start := pos
+			for start > 0 && unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+			refresh()
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			refresh()
+		case keyUp, keyCtrlP:
+			if historyIdx > 0 {
+				if historyIdx == len(e.history) {
+					saved = string(buf)
+				}
+				historyIdx--
+				setLine(e.history[historyIdx])
+			}
+		case keyDown, keyCtrlN:
+			if historyIdx < len(e.history) {
+				historyIdx++
+				if historyIdx == len(e.history) {
+					setLine(saved)
+				} else {
+					setLine(e.history[historyIdx])
+				}
+			}
+		case keyCtrlR:
+			line, ok, err := e.reverseSearch(string(buf))
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				buf = []rune(line)
+				pos = len(buf)
+			}
+			refresh()
+		case keyTab:
+			buf, pos = e.completeLine(buf, pos, lastWasTab)
+			lastWasTab = true
+			refresh()
+		case keyEscape, keyUnknown:
+		default:
+			if key >= 32 {
+				buf = append(buf[:pos], append([]rune{key}, buf[pos:]...)...)
+				pos++
+				refresh()
+			}
+		}
+	}
+}
+
+func (e *lineEditor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != keyEscape {
+		return r, nil
+	}
+
+	next, ok, err := e.readRuneTimeout(escapeTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return keyEscape, nil
+	}
+	if next != '[' && next != 'O' {
+		return keyUnknown, nil
+	}
+
+	code, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch code {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	}
+
+	if code < '0' || code > '9' {
+		return keyUnknown, nil
+	}
+	digits := string(code)
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if c == '~' {
+			break
+		}
+		if c < '0' || c > '9' {
+			return keyUnknown, nil
+		}
+		digits += string(c)
+	}
+	switch digits {
+	case "1", "7":
+		return keyHome, nil
+	case "4", "8":
+		return keyEnd, nil
+	case "3":
+		return keyDeleteForward, nil
+	}
+	return keyUnknown, nil
+}
+
+func (e *lineEditor) readRuneTimeout(timeout time.Duration) (rune, bool, error) {
+	if e.in.Buffered() == 0 {
+		if restore, err := setReadTimeout(e.fd, timeout); err == nil {
+			defer restore()
+		}
+	}
+	r, _, err := e.in.ReadRune()
+	if err == io.EOF {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return r, true, nil
+}
+
+func (e *lineEditor) reverseSearch(initial string) (string, bool, error) {
+	query := []rune{}
+	match := ""
+	matchIdx := len(e.history)
+
+	find := func(from int) {
+		for idx := from - 1; idx >= 0; idx-- {
+			if strings.Contains(e.history[idx], string(query)) {
+				match = e.history[idx]
+				matchIdx = idx
+				return
+			}
+		}
+	}
+	show := func() {
+		fmt.Fprintf(e.out, "\r(поиск)'%s': %s\x1b[K", string(query), match)
+	}
+
+	show()
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", false, err
+		}
+		switch key {
+		case keyCtrlR:
+			find(matchIdx)
+		case keyBackspace, keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				matchIdx = len(e.history)
+				match = ""
+				if len(query) > 0 {
+					find(matchIdx)
+				}
+			}
+		case keyCtrlG, keyCtrlC, keyEscape:
+			return initial, false, nil
+		case keyEnter, keyCtrlJ:
+			return match, match != "", nil
+		default:
+			if key >= 32 {
+				query = append(query, key)
+				matchIdx = len(e.history)
+				match = ""
+				find(matchIdx)
+			} else {
+				return match, match != "", nil
+			}
+		}
+		show()
+	}
+}
+
+func (e *lineEditor) completeLine(buf []rune, pos int, listAll bool) ([]rune, int) {
+	if e.complete == nil {
+		return buf, pos
+	}
+
+	before := string(buf[:pos])
+	start, candidates := e.complete(before)
+	if len(candidates) == 0 {
+		return buf, pos
+	}
+
+	startRune := len([]rune(before[:start]))
+	prefix := commonPrefix(candidates)
+	typed := buf[startRune:pos]
+
+	if len([]rune(prefix)) > len(typed) {
+		insert := []rune(prefix)
+		newBuf := append([]rune{}, buf[:startRune]...)
+		newBuf = append(newBuf, insert...)
+		newBuf = append(newBuf, buf[pos:]...)
+		return newBuf, startRune + len(insert)
+	}
+
+	if len(candidates) > 1 && listAll {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+	return buf, pos
+}
+
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := []rune(words[0])
+	for _, word := range words[1:] {
+		runes := []rune(word)
+		n := 0
+		for n < len(prefix) && n < len(runes) && prefix[n] == runes[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
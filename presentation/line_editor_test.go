@@ -0,0 +1,193 @@
+package presentation
+
+import (
+	"bufio"
+	"bytes"
+	"calculator/business"
+	"calculator/storage"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestEditor(input string, history []string, complete completer) (*lineEditor, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &lineEditor{
+		in:       bufio.NewReader(strings.NewReader(input)),
+		out:      out,
+		fd:       -1,
+		history:  history,
+		complete: complete,
+	}, out
+}
+
+func TestCommonPrefix(t *testing.T) {
+	cases := []struct {
+		words    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"history"}, "history"},
+		{[]string{"history", "help"}, "h"},
+		{[]string{"curl ", "cache clear", "config show"}, "c"},
+		{[]string{"привет", "приход"}, "при"},
+		{[]string{"abc", "xyz"}, ""},
+	}
+	for _, c := range cases {
+		if got := commonPrefix(c.words); got != c.expected {
+			t.Errorf("commonPrefix(%q) = %q, ожидалось %q", c.words, got, c.expected)
+		}
+	}
+}
+
+func TestCompleteLine(t *testing.T) {
+	complete := func(line string) (int, []string) {
+		start := strings.LastIndex(line, " ") + 1
+		var candidates []string
+		for _, word := range []string{"history", "help", "переменная", "перевод"} {
+			if strings.HasPrefix(word, line[start:]) {
+				candidates = append(candidates, word)
+			}
+		}
+		return start, candidates
+	}
+
+	cases := []struct {
+		line     string
+		pos      int
+		listAll  bool
+		expected string
+		pos2     int
+		listed   bool
+	}{
+		{"hi", 2, false, "history", 7, false},
+		{"he", 2, false, "help", 4, false},
+		{"h", 1, false, "h", 1, false},
+		{"h", 1, true, "h", 1, true},
+		{"x = пер", 7, false, "x = пере", 8, false},
+		{"x = пе + 1", 6, false, "x = пере + 1", 8, false},
+		{"zz", 2, true, "zz", 2, false},
+	}
+	for _, c := range cases {
+		editor, out := newTestEditor("", nil, complete)
+		buf, pos := editor.completeLine([]rune(c.line), c.pos, c.listAll)
+		if string(buf) != c.expected || pos != c.pos2 {
+			t.Errorf("completeLine(%q, %d) = %q, %d; ожидалось %q, %d", c.line, c.pos, string(buf), pos, c.expected, c.pos2)
+		}
+		if listed := out.Len() > 0; listed != c.listed {
+			t.Errorf("completeLine(%q, %d): вывод списка %v, ожидалось %v", c.line, c.pos, listed, c.listed)
+		}
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	history := []string{"curl https://a", "x = 5", "curl https://b"}
+	cases := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{"cu\r", "curl https://b", true},
+		{"cu\x12\r", "curl https://a", true},
+		{"x\r", "x = 5", true},
+		{"cux\x7f\r", "curl https://b", true},
+		{"zz\r", "", false},
+		{"cu\x07", "начало", false},
+		{"cu\x1b", "начало", false},
+		{"cu\x01", "curl https://b", true},
+	}
+	for _, c := range cases {
+		editor, _ := newTestEditor(c.input, history, nil)
+		line, ok, err := editor.reverseSearch("начало")
+		if err != nil {
+			t.Errorf("reverseSearch(%q): %v", c.input, err)
+			continue
+		}
+		if line != c.expected || ok != c.ok {
+			t.Errorf("reverseSearch(%q) = %q, %v; ожидалось %q, %v", c.input, line, ok, c.expected, c.ok)
+		}
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []rune
+	}{
+		{"a", []rune{'a'}},
+		{"я", []rune{'я'}},
+		{"\r", []rune{keyEnter}},
+		{"\x1b[A\x1b[B\x1b[C\x1b[D", []rune{keyUp, keyDown, keyRight, keyLeft}},
+		{"\x1bOH\x1bOF", []rune{keyHome, keyEnd}},
+		{"\x1b[1~\x1b[4~\x1b[7~\x1b[8~", []rune{keyHome, keyEnd, keyHome, keyEnd}},
+		{"\x1b[3~x", []rune{keyDeleteForward, 'x'}},
+		{"\x1b[15~", []rune{keyUnknown}},
+		{"\x1b[5;", []rune{keyUnknown}},
+		{"\x1bx", []rune{keyUnknown}},
+		{"\x1b[Z", []rune{keyUnknown}},
+		{"\x1b", []rune{keyEscape}},
+	}
+	for _, c := range cases {
+		editor, _ := newTestEditor(c.input, nil, nil)
+		var keys []rune
+		for range c.expected {
+			key, err := editor.readKey()
+			if err != nil {
+				t.Fatalf("readKey(%q): %v", c.input, err)
+			}
+			keys = append(keys, key)
+		}
+		if !reflect.DeepEqual(keys, c.expected) {
+			t.Errorf("readKey(%q) = %v, ожидалось %v", c.input, keys, c.expected)
+		}
+	}
+}
+
+func TestCLICompleteFunctions(t *testing.T) {
+	historyRepo := storage.NewHistoryRepositoryAt(filepath.Join(t.TempDir(), "history.txt"))
+	interpreter := business.NewInterpreter(historyRepo)
+	if _, err := interpreter.Execute("json = 5"); err != nil {
+		t.Fatalf("Присваивание не удалось: %v", err)
+	}
+	cli := NewCLI(interpreter, historyRepo)
+
+	cases := []struct {
+		line     string
+		start    int
+		expected []string
+	}{
+		{"x = jsonp", 4, []string{"jsonpath("}},
+		{"x = js", 4, []string{"jsonpath(", "json"}},
+		{"1 + json", 4, []string{"jsonpath("}},
+		{"x = s", 4, []string{"sin(", "sqrt("}},
+		{"2 * lo", 4, []string{"log("}},
+		{"x = q", 4, nil},
+	}
+	for _, c := range cases {
+		start, candidates := cli.complete(c.line)
+		if start != c.start || !reflect.DeepEqual(candidates, c.expected) {
+			t.Errorf("complete(%q) = %d, %q; ожидалось %d, %q", c.line, start, candidates, c.start, c.expected)
+		}
+	}
+
+	calls := []struct {
+		expr     string
+		expected float64
+	}{
+		{"sqrt(16)", 4},
+		{"2 * sqrt(16) + 1", 9},
+		{"log(100)", 2},
+		{"abs(2 - 5)", 3},
+		{"round(sqrt(2) * 10)", 14},
+	}
+	for _, c := range calls {
+		result, err := interpreter.Execute(c.expr)
+		if err != nil || result != c.expected {
+			t.Errorf("%s = %v, %v; ожидалось %v", c.expr, result, err, c.expected)
+		}
+	}
+	if _, err := interpreter.Execute("sqrt(-1)"); err == nil {
+		t.Error("sqrt(-1) должен возвращать ошибку")
+	}
+}
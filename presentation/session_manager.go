@@ -0,0 +1,153 @@
+package presentation
+
+import (
+	"calculator/business"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName  = "calc_session"
+	sessionHeaderName  = "X-Session-Token"
+	sessionSweepPeriod = time.Minute
+	defaultMaxSessions = 1000
+)
+
+type clientSession struct {
+	interpreter *business.Interpreter
+	lastSeen    time.Time
+}
+
+type SessionManager struct {
+	mu             sync.Mutex
+	sessions       map[string]*clientSession
+	idleTimeout    time.Duration
+	maxSessions    int
+	lastSweep      time.Time
+	newInterpreter func() *business.Interpreter
+}
+
+func NewSessionManager(newInterpreter func() *business.Interpreter, idleTimeout time.Duration) *SessionManager {
+	return &SessionManager{
+		sessions:       make(map[string]*clientSession),
+		idleTimeout:    idleTimeout,
+		maxSessions:    defaultMaxSessions,
+		lastSweep:      time.Now(),
+		newInterpreter: newInterpreter,
+	}
+}
+
+func (m *SessionManager) SetMaxSessions(maxSessions int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxSessions = maxSessions
+}
+
+func (m *SessionManager) Lookup(r *http.Request) (*business.Interpreter, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session := m.active(r, time.Now()); session != nil {
+		return session.interpreter, true
+	}
+	return nil, false
+}
+
+func (m *SessionManager) Interpreter(w http.ResponseWriter, r *http.Request) *business.Interpreter {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= sessionSweepPeriod {
+		m.removeExpired(now)
+		m.lastSweep = now
+	}
+
+	if session := m.active(r, now); session != nil {
+		return session.interpreter
+	}
+
+	if m.maxSessions > 0 && len(m.sessions) >= m.maxSessions {
+		m.removeExpired(now)
+		for len(m.sessions) >= m.maxSessions {
+			m.removeOldest()
+		}
+	}
+
+	token := newSessionToken()
+	session := &clientSession{interpreter: m.newInterpreter(), lastSeen: now}
+	session.interpreter.SetRemote(true)
+	if acceptLanguage := r.Header.Get("Accept-Language"); acceptLanguage != "" {
+		session.interpreter.SetLocale(business.ParseAcceptLanguage(acceptLanguage))
+	}
+	m.sessions[token] = session
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set(sessionHeaderName, token)
+
+	return session.interpreter
+}
+
+func (m *SessionManager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+func (m *SessionManager) active(r *http.Request, now time.Time) *clientSession {
+	token := sessionToken(r)
+	if token == "" {
+		return nil
+	}
+	session, ok := m.sessions[token]
+	if !ok || now.Sub(session.lastSeen) >= m.idleTimeout {
+		return nil
+	}
+	session.lastSeen = now
+	return session
+}
+
+func (m *SessionManager) removeOldest() {
+	var oldestToken string
+	var oldest *clientSession
+	for token, session := range m.sessions {
+		if oldest == nil || session.lastSeen.Before(oldest.lastSeen) {
+			oldestToken, oldest = token, session
+		}
+	}
+	delete(m.sessions, oldestToken)
+}
+
+func (m *SessionManager) removeExpired(now time.Time) {
+	for token, session := range m.sessions {
+		if now.Sub(session.lastSeen) >= m.idleTimeout {
+			delete(m.sessions, token)
+		}
+	}
+}
+
+func sessionToken(r *http.Request) string {
+	if token := r.Header.Get(sessionHeaderName); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func newSessionToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package presentation
+
+import (
+	"fmt"
+	"time"
+)
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, fmt.Errorf("raw mode не поддерживается на этой платформе")
+}
+
+func setReadTimeout(fd int, timeout time.Duration) (func(), error) {
+	return nil, fmt.Errorf("raw mode не поддерживается на этой платформе")
+}
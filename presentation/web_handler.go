@@ -4,48 +4,161 @@ import (
 	"calculator/business"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 )
 
 type WebHandler struct {
-	interpreter *business.Interpreter
+	sessions *SessionManager
+	identify func(r *http.Request) string
 }
 
-func NewWebHandler(interpreter *business.Interpreter) *WebHandler {
-	return &WebHandler{interpreter: interpreter}
+func NewWebHandler(sessions *SessionManager) *WebHandler {
+	return &WebHandler{sessions: sessions}
 }
 
-func (h *WebHandler) CalculateHandler(w http.ResponseWriter, r *http.Request) {
+func (h *WebHandler) SetIdentify(identify func(r *http.Request) string) {
+	h.identify = identify
+}
+
+func (h *WebHandler) interpreter(w http.ResponseWriter, r *http.Request) *business.Interpreter {
+	interpreter := h.sessions.Interpreter(w, r)
+	if h.identify != nil {
+		interpreter.SetUser(h.identify(r))
+	}
+	return interpreter
+}
+
+type calculateRequest struct {
+	Command string `json:"command"`
+	Locale  string `json:"locale"`
+	Explain bool   `json:"explain"`
+	NoCache bool   `json:"noCache"`
+}
+
+func decodeCalculateRequest(w http.ResponseWriter, r *http.Request) (calculateRequest, bool) {
+	var req calculateRequest
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return req, false
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func (h *WebHandler) CalculateHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeCalculateRequest(w, r)
+	if !ok {
 		return
 	}
 
-	var req struct {
-		Command string `json:"command"`
+	interpreter := h.interpreter(w, r)
+	if locale, ok := business.ParseLocale(req.Locale); ok {
+		interpreter.SetLocale(locale)
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	ctx := r.Context()
+	if req.NoCache {
+		ctx = business.WithoutCache(ctx)
+	}
+
+	var result *business.Result
+	var err error
+	if req.Explain {
+		result, err = interpreter.Explain(ctx, req.Command)
+	} else {
+		result, err = interpreter.ExecuteResult(ctx, req.Command)
+	}
+	json.NewEncoder(w).Encode(calculateResponse(interpreter, result, err))
+}
+
+func (h *WebHandler) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeCalculateRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Command == "" {
+		http.Error(w, "command required", http.StatusBadRequest)
 		return
 	}
 
-	result, err := h.interpreter.Execute(req.Command)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	interpreter := h.interpreter(w, r)
+	if locale, ok := business.ParseLocale(req.Locale); ok {
+		interpreter.SetLocale(locale)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx := business.WithTokenStream(r.Context(), func(delta string) {
+		writeEvent(w, "token", map[string]string{"text": delta})
+		flusher.Flush()
+	})
+	ctx = business.WithProgress(ctx, func(progress business.Progress) {
+		writeEvent(w, "progress", progress)
+		flusher.Flush()
+	})
+	if req.NoCache {
+		ctx = business.WithoutCache(ctx)
+	}
+	result, err := interpreter.ExecuteResult(ctx, req.Command)
+	if r.Context().Err() != nil {
+		return
+	}
+
+	writeEvent(w, "result", calculateResponse(interpreter, result, err))
+	flusher.Flush()
+}
+
+func calculateResponse(interpreter *business.Interpreter, result *business.Result, err error) map[string]interface{} {
 	if err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		return map[string]interface{}{
 			"success": false,
-			"message": "Ошибка: " + err.Error(),
-		})
-		return
+			"message": business.Message(interpreter.Locale(), "ui.error") + ": " + err.Error(),
+		}
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	return map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("%v", result),
-	})
+		"message": result.Text,
+		"ref":     result.Ref,
+		"result":  result,
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
 }
 
 func (h *WebHandler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
-	history := h.interpreter.GetHistory()
+	history := []string{}
+	if interpreter, ok := h.sessions.Lookup(r); ok {
+		history = interpreter.GetHistory()
+	}
 	json.NewEncoder(w).Encode(history)
 }
+
+func (h *WebHandler) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(h.interpreter(w, r).Usage())
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"calculator/config"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,7 +17,7 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-var jwtKey = []byte("verysecretkey")
+var jwtKey []byte
 
 type User struct {
 	Name string
@@ -47,6 +49,15 @@ func NewServer() *Server {
 }
 
 func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.RequireJWTSecret(); err != nil {
+		log.Fatal(err)
+	}
+	jwtKey = []byte(cfg.JWTSecret)
+
 	s := NewServer()
 	http.Handle("/", http.FileServer(http.Dir("./signaling/static")))
 
@@ -95,8 +106,8 @@ func main() {
 		log.Printf("=== ДАННЫЕ ОТПРАВЛЕНЫ ===")
 	})
 
-	log.Println("Signaling server listening :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Printf("Signaling server listening %s", cfg.ListenAddr)
+	log.Fatal(http.ListenAndServe(cfg.ListenAddr, nil))
 }
 
 func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
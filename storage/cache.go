@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const cacheSaveDelay = time.Second
+
+type CacheEntry struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+}
+
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int   `json:"bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+type ResponseCache struct {
+	mu         sync.Mutex
+	writeMu    sync.Mutex
+	filename   string
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+	entries    map[string]*CacheEntry
+	bytes      int
+	stats      CacheStats
+	now        func() time.Time
+	dirty      bool
+	saveTimer  *time.Timer
+}
+
+func NewResponseCache(filename string, ttl time.Duration, maxEntries, maxBytes int) *ResponseCache {
+	return &ResponseCache{
+		filename:   filename,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    make(map[string]*CacheEntry),
+		now:        time.Now,
+	}
+}
+
+func (c *ResponseCache) Load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []*CacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	c.entries = make(map[string]*CacheEntry, len(entries))
+	c.bytes = 0
+	now := c.now()
+	for _, entry := range entries {
+		if now.Before(entry.ExpiresAt) {
+			c.entries[entry.Key] = entry
+			c.bytes += entrySize(entry)
+		}
+	}
+	c.evict()
+	return nil
+}
+
+func (c *ResponseCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && !c.now().Before(entry.ExpiresAt) {
+		c.remove(entry)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return "", false
+	}
+
+	c.stats.Hits++
+	entry.LastUsed = c.now()
+	return entry.Value, true
+}
+
+func (c *ResponseCache) Put(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.entries[key]; ok {
+		c.remove(existing)
+	}
+
+	now := c.now()
+	entry := &CacheEntry{Key: key, Value: value, CreatedAt: now, ExpiresAt: now.Add(c.ttl), LastUsed: now}
+	if c.maxBytes <= 0 || entrySize(entry) <= c.maxBytes {
+		c.entries[key] = entry
+		c.bytes += entrySize(entry)
+		c.evict()
+	}
+	c.scheduleSave()
+}
+
+func (c *ResponseCache) Clear() error {
+	c.mu.Lock()
+	c.entries = make(map[string]*CacheEntry)
+	c.bytes = 0
+	c.dirty = true
+	c.mu.Unlock()
+	return c.Flush()
+}
+
+func (c *ResponseCache) Flush() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	if c.saveTimer != nil {
+		c.saveTimer.Stop()
+		c.saveTimer = nil
+	}
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	data, err := c.snapshot()
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := c.write(data); err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (c *ResponseCache) scheduleSave() {
+	c.dirty = true
+	if c.saveTimer != nil {
+		return
+	}
+	c.saveTimer = time.AfterFunc(cacheSaveDelay, func() {
+		if err := c.Flush(); err != nil {
+			log.Printf("Ошибка сохранения кэша ответов AI: %v", err)
+		}
+	})
+}
+
+func (c *ResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Bytes = c.bytes
+	return stats
+}
+
+func (c *ResponseCache) evict() {
+	now := c.now()
+	for _, entry := range c.entries {
+		if !now.Before(entry.ExpiresAt) {
+			c.remove(entry)
+			c.stats.Evictions++
+		}
+	}
+
+	if c.withinLimits() {
+		return
+	}
+
+	entries := make([]*CacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].LastUsed.Before(entries[b].LastUsed)
+	})
+	for _, entry := range entries {
+		if c.withinLimits() {
+			break
+		}
+		c.remove(entry)
+		c.stats.Evictions++
+	}
+}
+
+func (c *ResponseCache) withinLimits() bool {
+	return (c.maxEntries <= 0 || len(c.entries) <= c.maxEntries) &&
+		(c.maxBytes <= 0 || c.bytes <= c.maxBytes)
+}
+
+func (c *ResponseCache) remove(entry *CacheEntry) {
+	delete(c.entries, entry.Key)
+	c.bytes -= entrySize(entry)
+}
+
+func (c *ResponseCache) snapshot() ([]byte, error) {
+	entries := make([]*CacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].CreatedAt.Before(entries[b].CreatedAt)
+	})
+
+	return json.Marshal(entries)
+}
+
+func (c *ResponseCache) write(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.filename), filepath.Base(c.filename)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.filename)
+}
+
+func entrySize(entry *CacheEntry) int {
+	return len(entry.Key) + len(entry.Value)
+}
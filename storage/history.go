@@ -2,40 +2,99 @@ package storage
 
 import (
 	"bufio"
+	"encoding/json"
 	"os"
+	"strings"
+	"sync"
 )
 
+type HistoryEntry struct {
+	Command string `json:"command"`
+	Result  string `json:"result,omitempty"`
+}
+
 type HistoryRepository struct {
+	mu       sync.Mutex
 	filename string
 }
 
 func NewHistoryRepository() *HistoryRepository {
-	return &HistoryRepository{filename: "history.txt"}
+	return NewHistoryRepositoryAt("history.txt")
+}
+
+func NewHistoryRepositoryAt(filename string) *HistoryRepository {
+	return &HistoryRepository{filename: filename}
 }
 
 func (h *HistoryRepository) AddCommand(command string) {
-	file, _ := os.OpenFile(h.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	h.appendLine([]byte(command))
+}
+
+func (h *HistoryRepository) AddEntry(command string, result string) {
+	if result == "" {
+		h.AddCommand(command)
+		return
+	}
+
+	data, err := json.Marshal(HistoryEntry{Command: command, Result: result})
+	if err != nil {
+		h.AddCommand(command)
+		return
+	}
+	h.appendLine(data)
+}
+
+func (h *HistoryRepository) appendLine(line []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	file, err := os.OpenFile(h.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
 	defer file.Close()
-	file.WriteString(command + "\n")
+	file.Write(append(line, '\n'))
 }
 
 func (h *HistoryRepository) GetLastCommands(n int) []string {
+	entries := h.GetLastEntries(n)
+	commands := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		commands = append(commands, entry.Command)
+	}
+	return commands
+}
+
+func (h *HistoryRepository) GetLastEntries(n int) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	file, err := os.Open(h.filename)
 	if err != nil {
-		return []string{}
+		return []HistoryEntry{}
 	}
 	defer file.Close()
 
-	var commands []string
+	var entries []HistoryEntry
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		commands = append(commands, scanner.Text())
+		entries = append(entries, parseHistoryLine(scanner.Text()))
 	}
 
-	if len(commands) > n {
-		return commands[len(commands)-n:]
+	if len(entries) > n {
+		return entries[len(entries)-n:]
 	}
-	return commands
+	return entries
+}
+
+func parseHistoryLine(line string) HistoryEntry {
+	if strings.HasPrefix(line, "{") {
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err == nil && entry.Command != "" {
+			return entry
+		}
+	}
+	return HistoryEntry{Command: line}
 }
 
 func (h *HistoryRepository) Restore() error {
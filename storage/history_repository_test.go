@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -50,3 +52,68 @@ func TestHistoryRepository(t *testing.T) {
 		}
 	})
 }
+
+func TestHistoryEntries(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "history_entries_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+	repo := &HistoryRepository{filename: tempFile.Name()}
+	repo.AddCommand("old command")
+	repo.AddEntry("2+2", "4")
+	repo.AddEntry("bad input", "")
+
+	entries := repo.GetLastEntries(10)
+	expected := []HistoryEntry{
+		{Command: "old command"},
+		{Command: "2+2", Result: "4"},
+		{Command: "bad input"},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if entry != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], entry)
+		}
+	}
+
+	commands := repo.GetLastCommands(1)
+	if len(commands) != 1 || commands[0] != "bad input" {
+		t.Errorf("Expected only the last command, got %v", commands)
+	}
+}
+
+func TestHistoryRepositoryConcurrentWrites(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "history_concurrent_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+	repo := &HistoryRepository{filename: tempFile.Name()}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 10; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				repo.AddEntry(fmt.Sprintf("%d+%d", worker, n), fmt.Sprintf("%d", worker+n))
+				repo.GetLastCommands(5)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	entries := repo.GetLastEntries(1000)
+	if len(entries) != 500 {
+		t.Fatalf("Expected 500 entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Command == "" || entry.Result == "" {
+			t.Errorf("Corrupted entry: %+v", entry)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	usageDayLayout = "2006-01-02"
+	usageKeepDays  = 31
+)
+
+type TokenUsage struct {
+	Requests         int `json:"requests"`
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+func (u TokenUsage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.Requests += other.Requests
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+}
+
+type UsageStore struct {
+	mu       sync.Mutex
+	filename string
+	days     map[string]map[string]TokenUsage
+	now      func() time.Time
+}
+
+func NewUsageStore(filename string) *UsageStore {
+	return &UsageStore{
+		filename: filename,
+		days:     make(map[string]map[string]TokenUsage),
+		now:      time.Now,
+	}
+}
+
+func (s *UsageStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	days := make(map[string]map[string]TokenUsage)
+	if err := json.Unmarshal(data, &days); err != nil {
+		return err
+	}
+	s.days = days
+	s.prune()
+	return nil
+}
+
+func (s *UsageStore) Record(user string, usage TokenUsage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := s.today()
+	users, ok := s.days[day]
+	if !ok {
+		users = make(map[string]TokenUsage)
+		s.days[day] = users
+	}
+	total := users[user]
+	total.Add(usage)
+	users[user] = total
+
+	s.prune()
+	return s.save()
+}
+
+func (s *UsageStore) Today(user string) TokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.days[s.today()][user]
+}
+
+func (s *UsageStore) TodayByUser() map[string]TokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make(map[string]TokenUsage, len(s.days[s.today()]))
+	for user, usage := range s.days[s.today()] {
+		users[user] = usage
+	}
+	return users
+}
+
+func (s *UsageStore) today() string {
+	return s.now().Format(usageDayLayout)
+}
+
+func (s *UsageStore) prune() {
+	oldest := s.now().AddDate(0, 0, -usageKeepDays).Format(usageDayLayout)
+	for day := range s.days {
+		if day < oldest {
+			delete(s.days, day)
+		}
+	}
+}
+
+func (s *UsageStore) save() error {
+	data, err := json.MarshalIndent(s.days, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename)
+}